@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/threft/threft/tidm"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// checkCommand parses and validates the input documents
+type checkCommand struct {
+	Strict bool `long:"strict" description:"Treat warnings as errors"`
+}
+
+func (cmd *checkCommand) Execute(args []string) error {
+	checkArguments(args)
+
+	_, diags := parseInputs()
+	if cmd.Strict && diags.Count(tidm.SeverityWarning) > 0 {
+		exitWithError(exitInvalidIDL, "\nFound %d warnings, failing because of --strict.\n", diags.Count(tidm.SeverityWarning))
+	}
+
+	logInfof("All documents are valid.\n")
+	return nil
+}
+
+// generateCommand parses the input documents and runs a generator
+type generateCommand struct {
+	Generator string `short:"g" long:"gen" description:"Generator to use (for example: go, html), can include arguments for generator"`
+	OutputDir string `short:"o" long:"output" description:"Folder to generate code to"`
+	DumpTIDM  bool   `long:"dump-tidm" description:"Dumps TIDM structure to ./tidm_dump"`
+	Emit      string `long:"emit" value-name:"tidm-json" description:"Write the parsed TIDM to stdout instead of running a generator, the only supported format is tidm-json"`
+}
+
+func (cmd *generateCommand) Execute(args []string) error {
+	checkArguments(args)
+
+	// check emit format
+	if len(cmd.Emit) > 0 && cmd.Emit != "tidm-json" {
+		exitWithError(exitUsage, "Unknown format '%s' for --emit, expecting tidm-json.\n", cmd.Emit)
+	}
+
+	// get generator fields (possibly options)
+	genFields := strings.Fields(cmd.Generator)
+	if len(genFields) == 0 && len(cmd.Emit) == 0 {
+		exitWithError(exitUsage, "%s", "No generator given. Can not continue. Use -g to generate code.\n")
+	}
+
+	outputDir, err := filepath.Abs(cmd.OutputDir)
+	if err != nil {
+		exitWithError(exitIOError, "Error getting absolute path for '%s': %s\n", cmd.OutputDir, err)
+	}
+
+	t, _ := parseInputs()
+
+	// do a TIDM dump if requested by user
+	if cmd.DumpTIDM {
+		err = dumpTIDM(t)
+		if err != nil {
+			// TODO output a formatted message like in all other
+			// cases?
+			exitWithError(exitIOError, "%s\n", err)
+		}
+	}
+
+	// write tidm-json to stdout when requested by user, no generator is used
+	if cmd.Emit == "tidm-json" {
+		err = t.EncodeTo(os.Stdout)
+		if err != nil {
+			exitWithError(exitIOError, "Error writing tidm-json to stdout: %s\n", err)
+		}
+		return nil
+	}
+
+	// prepare generator command
+	logDebugf("Running generator 'threft-gen-%s' in '%s'.\n", strings.Join(genFields, " "), outputDir)
+	genCmd := exec.Command("threft-gen-"+genFields[0], genFields[1:]...)
+	genCmd.Dir = outputDir
+	genCmd.Stderr = os.Stderr
+	genCmd.Stdout = os.Stdout
+
+	// get stdinPipe to send json when process has started
+	stdinPipe, err := genCmd.StdinPipe()
+	if err != nil {
+		exitWithError(exitIOError, "Error getting stdin pipe: %s\n", err)
+	}
+
+	// start generator
+	err = genCmd.Start()
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			exitWithError(exitGeneratorNotFound, "Generator 'threft-gen-%s' was not found: %s\n", genFields[0], err)
+		}
+		exitWithError(exitGeneratorFailed, "Error on starting generator: %s\n", err)
+	}
+
+	// write tidm-json to generator
+	// when writing fails the generator has most likely exited, its exit status is checked below
+	writeErr := t.EncodeTo(stdinPipe)
+	if writeErr != nil {
+		logErrorf("Error writing data to generator: %s\n", writeErr)
+	}
+
+	// close the stdinPipe
+	err = stdinPipe.Close()
+	if err != nil {
+		logErrorf("Error closing stdin pipe: %s\n", err)
+	}
+
+	// wait for generator to exit, and propagate its exit status
+	err = genCmd.Wait()
+	if err != nil {
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok || exitErr.ExitCode() < 0 {
+			exitWithError(exitGeneratorFailed, "Error while running generator: %s\n", err)
+		}
+		code := generatorExitCode(exitErr.ExitCode())
+		if code != exitErr.ExitCode() {
+			exitWithError(code, "Generator failed: %s (reported as exit code %d, the status is reserved by threft)\n", err, code)
+		}
+		exitWithError(code, "Generator failed: %s\n", err)
+	}
+	if writeErr != nil {
+		os.Exit(exitIOError)
+	}
+
+	logInfof("All done.\n")
+	return nil
+}
+
+// dumpCommand parses the input documents and writes the TIDM
+type dumpCommand struct {
+	Format string `long:"format" default:"tidm-json" choice:"tidm-json" choice:"spew" description:"Format of the dump"`
+	Output string `short:"o" long:"output" description:"File to write the dump to, the dump is written to stdout when no file is given"`
+}
+
+func (cmd *dumpCommand) Execute(args []string) error {
+	checkArguments(args)
+
+	t, _ := parseInputs()
+
+	var w io.Writer = os.Stdout
+	if len(cmd.Output) > 0 {
+		file, err := os.Create(cmd.Output)
+		if err != nil {
+			exitWithError(exitIOError, "Error creating dump file: %s\n", err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	switch cmd.Format {
+	case "spew":
+		writeSpewDump(w, t)
+	default:
+		err := t.EncodeTo(w)
+		if err != nil {
+			exitWithError(exitIOError, "Error writing tidm-json: %s\n", err)
+		}
+	}
+	return nil
+}
+
+// fmtCommand formats the input documents
+type fmtCommand struct {
+	Write bool `short:"w" long:"write" description:"Write the result to the source file instead of stdout"`
+	List  bool `short:"l" long:"list" description:"List files whose formatting differs, exits with 65 when a file is listed"`
+}
+
+func (cmd *fmtCommand) Execute(args []string) error {
+	checkArguments(args)
+
+	in := findInputs(newTIDM())
+	var differs, invalid bool
+
+	// formatDocument formats one document, it returns false when the document is invalid
+	// Each document is formatted in its own TIDM, documents with the same filename in different folders don't conflict.
+	formatDocument := func(t *tidm.TIDM, name tidm.DocumentName, source []byte, filename string) bool {
+		formatted, diags := t.Format(name)
+		if diags.HasErrors() {
+			err := writeDiagnostics(t, diags)
+			if err != nil {
+				exitWithError(exitIOError, "Error writing diagnostics: %s\n", err)
+			}
+			return false
+		}
+
+		switch {
+		case cmd.List:
+			if !bytes.Equal(source, formatted) {
+				differs = true
+				fmt.Println(filename)
+			}
+		case cmd.Write && t.Documents[name].Path() != "":
+			if !bytes.Equal(source, formatted) {
+				err := os.WriteFile(filename, formatted, 0644)
+				if err != nil {
+					exitWithError(exitIOError, "Error writing '%s': %s\n", filename, err)
+				}
+			}
+		default:
+			_, err := os.Stdout.Write(formatted)
+			if err != nil {
+				exitWithError(exitIOError, "Error writing to stdout: %s\n", err)
+			}
+		}
+		return true
+	}
+
+	for _, filename := range in.filenames {
+		source, err := os.ReadFile(filename)
+		if err != nil {
+			exitWithError(exitIOError, "Error reading '%s': %s\n", filename, err)
+		}
+		t := newTIDM()
+		err = t.AddDocumentFileWithName(in.names[filename], filename)
+		if err != nil {
+			exitWithError(exitIOError, "Error adding document '%s': %s\n", filename, err)
+		}
+		if !formatDocument(t, in.names[filename], source, filename) {
+			invalid = true
+		}
+	}
+	if in.readStdin {
+		source, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			exitWithError(exitIOError, "Error reading stdin: %s\n", err)
+		}
+		t := newTIDM()
+		err = t.AddDocument(tidm.DocumentName("stdin"), bytes.NewReader(source))
+		if err != nil {
+			exitWithError(exitIOError, "Error adding document from stdin: %s\n", err)
+		}
+		if !formatDocument(t, tidm.DocumentName("stdin"), source, "stdin") {
+			invalid = true
+		}
+	}
+
+	if invalid || differs {
+		os.Exit(exitInvalidIDL)
+	}
+	return nil
+}
+
+// generatorsCommand lists the generators that are found in PATH
+type generatorsCommand struct {
+	Path bool `long:"path" description:"Show the full path of each generator"`
+}
+
+func (cmd *generatorsCommand) Execute(args []string) error {
+	checkArguments(args)
+
+	// the first generator with a name is used, like exec.LookPath does
+	const prefix = "threft-gen-"
+	generators := make(map[string]string)
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue // folders in PATH that do not exist are ignored
+		}
+		for _, entry := range entries {
+			if !strings.HasPrefix(entry.Name(), prefix) || entry.IsDir() {
+				continue
+			}
+			name := strings.TrimSuffix(strings.TrimPrefix(entry.Name(), prefix), ".exe")
+			if _, exists := generators[name]; exists {
+				continue
+			}
+			fullPath := filepath.Join(dir, entry.Name())
+			if _, err := exec.LookPath(fullPath); err != nil {
+				continue // not executable
+			}
+			generators[name] = fullPath
+		}
+	}
+
+	names := make([]string, 0, len(generators))
+	for name := range generators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if cmd.Path {
+			fmt.Printf("%s\t%s\n", name, generators[name])
+		} else {
+			fmt.Println(name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/threft/threft/tidm"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// diagnosticsFile is the file given with --diagnostics-output, it is created when diagnostics are first written
+var diagnosticsFile *os.File
+
+// diagnosticsWriter returns the writer for diagnostics, stderr or the file given with --diagnostics-output
+// Diagnostics are never written to stdout, stdout is used for generator output, dumps and formatted documents.
+func diagnosticsWriter() (io.Writer, error) {
+	if len(options.DiagnosticsOutput) == 0 {
+		return os.Stderr, nil
+	}
+	if diagnosticsFile == nil {
+		file, err := os.Create(options.DiagnosticsOutput)
+		if err != nil {
+			return nil, err
+		}
+		diagnosticsFile = file
+	}
+	return diagnosticsFile, nil
+}
+
+// writeDiagnostics writes the diagnostics in the format given by --diagnostics-format
+func writeDiagnostics(t *tidm.TIDM, diags tidm.Diagnostics) error {
+	w, err := diagnosticsWriter()
+	if err != nil {
+		return err
+	}
+	switch options.DiagnosticsFormat {
+	case "json":
+		return writeDiagnosticsJSON(w, t, diags)
+	case "sarif":
+		return writeDiagnosticsSARIF(w, t, diags)
+	}
+	printDiagnostics(w, t, diags)
+	return nil
+}
+
+// printDiagnostics prints the diagnostics as text, the number of printed errors is limited by --max-errors
+func printDiagnostics(w io.Writer, t *tidm.TIDM, diags tidm.Diagnostics) {
+	var errorCount int
+	for _, d := range diags {
+		if d.Severity == tidm.SeverityError {
+			errorCount++
+			if options.MaxErrors > 0 && errorCount > options.MaxErrors {
+				continue
+			}
+		}
+		fmt.Fprintf(w, "\n%s\n", d)
+		printSourceExcerpt(w, t, d.DocLine)
+	}
+	if options.MaxErrors > 0 && errorCount > options.MaxErrors {
+		fmt.Fprintf(w, "\n%d more errors not shown, use --max-errors to show more.\n", errorCount-options.MaxErrors)
+	}
+}
+
+// printSourceExcerpt prints the source line for given DocLine, with carets below the range
+// A range that spans multiple lines is marked until the end of the first line.
+func printSourceExcerpt(w io.Writer, t *tidm.TIDM, dl *tidm.DocLine) {
+	if dl == nil || t.Documents[dl.DocumentName] == nil {
+		return
+	}
+	line, ok := t.Documents[dl.DocumentName].SourceLine(dl.Line)
+	if !ok {
+		return
+	}
+	runes := []rune(line)
+
+	start, end := dl.Column, dl.EndColumn
+	if start > len(runes) {
+		start = len(runes)
+	}
+	if dl.EndLine != dl.Line || end > len(runes) {
+		end = len(runes)
+	}
+	if end <= start {
+		end = start + 1
+	}
+
+	// keep tabs from the source line, so the carets line up with the source
+	padding := make([]rune, start)
+	for i, r := range runes[:start] {
+		if r == '\t' {
+			padding[i] = '\t'
+		} else {
+			padding[i] = ' '
+		}
+	}
+
+	lineNumber := strconv.Itoa(dl.Line + 1)
+	fmt.Fprintf(w, " %s | %s\n", lineNumber, line)
+	fmt.Fprintf(w, " %s | %s%s\n", strings.Repeat(" ", len(lineNumber)), string(padding), strings.Repeat("^", end-start))
+}
+
+// jsonDiagnostic is a single line in the json diagnostics output
+// Lines and columns start at 1, the range ends before EndColumn.
+type jsonDiagnostic struct {
+	Severity  tidm.Severity       `json:"severity"`
+	Code      tidm.DiagnosticCode `json:"code"`
+	Message   string              `json:"message"`
+	File      string              `json:"file,omitempty"`
+	Line      int                 `json:"line,omitempty"`
+	Column    int                 `json:"column,omitempty"`
+	EndLine   int                 `json:"endLine,omitempty"`
+	EndColumn int                 `json:"endColumn,omitempty"`
+	Related   []string            `json:"related,omitempty"` // file:line:column for each related position
+}
+
+// writeDiagnosticsJSON writes each diagnostic as a json object on a single line
+func writeDiagnosticsJSON(w io.Writer, t *tidm.TIDM, diags tidm.Diagnostics) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	for _, d := range diags {
+		jd := &jsonDiagnostic{
+			Severity: d.Severity,
+			Code:     d.Code,
+			Message:  d.Message,
+		}
+		if d.DocLine != nil {
+			jd.File = diagnosticFile(t, d.DocLine)
+			jd.Line = d.DocLine.Line + 1
+			jd.Column = d.DocLine.Column + 1
+			jd.EndLine = d.DocLine.EndLine + 1
+			jd.EndColumn = d.DocLine.EndColumn + 1
+		}
+		for _, related := range d.Related {
+			jd.Related = append(jd.Related, fmt.Sprintf("%s:%d:%d", diagnosticFile(t, related), related.Line+1, related.Column+1))
+		}
+		err := enc.Encode(jd)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SARIF 2.1.0 log structure, only the properties used by threft are defined.
+// See https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+type (
+	sarifLog struct {
+		Schema  string     `json:"$schema"`
+		Version string     `json:"version"`
+		Runs    []sarifRun `json:"runs"`
+	}
+	sarifRun struct {
+		Tool       sarifTool     `json:"tool"`
+		Results    []sarifResult `json:"results"`
+		ColumnKind string        `json:"columnKind"`
+	}
+	sarifTool struct {
+		Driver sarifDriver `json:"driver"`
+	}
+	sarifDriver struct {
+		Name           string      `json:"name"`
+		InformationURI string      `json:"informationUri"`
+		Rules          []sarifRule `json:"rules"`
+	}
+	sarifRule struct {
+		ID string `json:"id"`
+	}
+	sarifResult struct {
+		RuleID           string          `json:"ruleId"`
+		Level            string          `json:"level"`
+		Message          sarifMessage    `json:"message"`
+		Locations        []sarifLocation `json:"locations,omitempty"`
+		RelatedLocations []sarifLocation `json:"relatedLocations,omitempty"`
+	}
+	sarifMessage struct {
+		Text string `json:"text"`
+	}
+	sarifLocation struct {
+		PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	}
+	sarifPhysicalLocation struct {
+		ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+		Region           sarifRegion           `json:"region"`
+	}
+	sarifArtifactLocation struct {
+		URI string `json:"uri"`
+	}
+	sarifRegion struct {
+		StartLine   int `json:"startLine"`
+		StartColumn int `json:"startColumn"`
+		EndLine     int `json:"endLine,omitempty"`
+		EndColumn   int `json:"endColumn,omitempty"`
+	}
+)
+
+// sarifLevels maps diagnostic severities to SARIF result levels
+var sarifLevels = map[tidm.Severity]string{
+	tidm.SeverityError:   "error",
+	tidm.SeverityWarning: "warning",
+	tidm.SeverityInfo:    "note",
+}
+
+// writeDiagnosticsSARIF writes the diagnostics as a SARIF 2.1.0 log with a single run
+func writeDiagnosticsSARIF(w io.Writer, t *tidm.TIDM, diags tidm.Diagnostics) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "threft",
+			InformationURI: "https://github.com/threft/threft",
+			Rules:          []sarifRule{},
+		}},
+		Results:    []sarifResult{},
+		ColumnKind: "unicodeCodePoints", // DocLine columns are counted in runes
+	}
+
+	rules := make(map[tidm.DiagnosticCode]bool)
+	for _, d := range diags {
+		if !rules[d.Code] {
+			rules[d.Code] = true
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: string(d.Code)})
+		}
+		result := sarifResult{
+			RuleID:  string(d.Code),
+			Level:   sarifLevels[d.Severity],
+			Message: sarifMessage{Text: d.Message},
+		}
+		if d.DocLine != nil {
+			result.Locations = []sarifLocation{sarifLocationFor(t, d.DocLine)}
+		}
+		for _, related := range d.Related {
+			result.RelatedLocations = append(result.RelatedLocations, sarifLocationFor(t, related))
+		}
+		run.Results = append(run.Results, result)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(&sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+}
+
+// sarifLocationFor creates a SARIF location for given DocLine
+func sarifLocationFor(t *tidm.TIDM, dl *tidm.DocLine) sarifLocation {
+	return sarifLocation{PhysicalLocation: sarifPhysicalLocation{
+		ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(diagnosticFile(t, dl))},
+		Region: sarifRegion{
+			StartLine:   dl.Line + 1,
+			StartColumn: dl.Column + 1,
+			EndLine:     dl.EndLine + 1,
+			EndColumn:   dl.EndColumn + 1,
+		},
+	}}
+}
+
+// diagnosticFile returns the file for given DocLine, relative to the working directory when possible.
+// The document name is returned for documents that were not read from a file.
+func diagnosticFile(t *tidm.TIDM, dl *tidm.DocLine) string {
+	doc := t.Documents[dl.DocumentName]
+	if doc == nil || len(doc.Path()) == 0 {
+		return string(dl.DocumentName)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return doc.Path()
+	}
+	rel, err := filepath.Rel(wd, doc.Path())
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return doc.Path()
+	}
+	return rel
+}
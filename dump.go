@@ -14,12 +14,11 @@ func dumpTIDM(t *tidm.TIDM) error {
 	if err != nil {
 		return fmt.Errorf("Error creating dumpfile: %s", err)
 	}
+	defer dumpFile.Close()
 
 	// write spew dump
 	io.WriteString(dumpFile, "spew.Dump:\n==========\n")
-	cs := spew.NewDefaultConfig()
-	cs.Indent = "    "
-	cs.Fdump(dumpFile, t)
+	writeSpewDump(dumpFile, t)
 	io.WriteString(dumpFile, "\n\n\n\n")
 
 	// write json
@@ -33,3 +32,10 @@ func dumpTIDM(t *tidm.TIDM) error {
 	// all done
 	return nil
 }
+
+// writeSpewDump writes a spew dump of the TIDM structure
+func writeSpewDump(w io.Writer, t *tidm.TIDM) {
+	cs := spew.NewDefaultConfig()
+	cs.Indent = "    "
+	cs.Fdump(w, t)
+}
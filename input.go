@@ -0,0 +1,199 @@
+package main
+
+import (
+	"github.com/threft/threft/tidm"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// inputs contains the files given with -i, after folders have been scanned
+type inputs struct {
+	filenames []string                     // absolute filenames, each file is listed once
+	names     map[string]tidm.DocumentName // document name for each file, see findInputs()
+	readStdin bool                         // a document is read from stdin, - was given as input
+}
+
+// findInputs searches the files and folders given with -i for IDL files
+func findInputs(t *tidm.TIDM) *inputs {
+	// check glob patterns before they are used
+	for _, pattern := range append(options.Include, options.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			exitWithError(exitUsage, "Error: invalid pattern '%s': %s\n", pattern, err)
+		}
+	}
+
+	// files found in a folder are named by their path relative to that folder, so files with the same name
+	// in different subfolders don't conflict. Files given directly are named by their base filename.
+	in := &inputs{names: make(map[string]tidm.DocumentName)}
+	filenameForName := make(map[tidm.DocumentName]string)
+	addFilename := func(filename string, name tidm.DocumentName) {
+		if _, exists := in.names[filename]; exists {
+			return
+		}
+		if existing, exists := filenameForName[name]; exists {
+			exitWithError(exitUsage, "Error: '%s' and '%s' both have document name '%s'. Give their common parent folder with -i instead.\n", existing, filename, name)
+		}
+		filenameForName[name] = filename
+		in.names[filename] = name
+		in.filenames = append(in.filenames, filename)
+	}
+
+	logInfof("Searching for IDL files and setting up documents.\n")
+	for _, filefolder := range options.InputFiles {
+		if filefolder == "-" {
+			in.readStdin = true
+			continue
+		}
+
+		filefolder, err := filepath.Abs(filefolder)
+		if err != nil {
+			exitWithError(exitIOError, "Error getting absolute path for '%s': %s\n", filefolder, err)
+		}
+
+		fi, err := os.Stat(filefolder)
+		if err != nil {
+			exitWithError(exitIOError, "Error getting info on '%s': %s\n", filefolder, err)
+		}
+
+		if fi.IsDir() {
+			// do recursive file find
+			scannedFilenames, err := scanDir(t, filefolder)
+			if err != nil {
+				exitWithError(exitIOError, "Error scanning '%s': %s\n", filefolder, err)
+			}
+
+			// log findings
+			logInfof("Found %d files in given path '%s'.\n", len(scannedFilenames), filefolder)
+			for _, filename := range scannedFilenames {
+				logDebugf("• %s\n", filename)
+				relPath, err := filepath.Rel(filefolder, filename)
+				if err != nil {
+					exitWithError(exitIOError, "Error getting relative path for '%s': %s\n", filename, err)
+				}
+				addFilename(filename, tidm.DocumentName(filepath.ToSlash(relPath)))
+			}
+		} else {
+			// only one file given
+			// check if file is IDL file
+			if !t.IsIDLFile(filefolder) {
+				exitWithError(exitUsage, "Error: invalid file extension for '%s' (expected %s).\n", filefolder, strings.Join(t.Extensions(), ", "))
+			}
+
+			// add filename to list
+			addFilename(filefolder, tidm.DocumentName(filepath.Base(filefolder)))
+		}
+	}
+
+	if len(in.filenames) == 0 && !in.readStdin {
+		exitWithError(exitUsage, "%s", "No input documents found. Use -i to give input files or folders.\n")
+	}
+	return in
+}
+
+// scanDir recursively searches the given folder for IDL files (see TIDM.IsIDLFile), other files are skipped.
+// Files and folders are filtered with the --include and --exclude patterns, see matchesPattern().
+func scanDir(t *tidm.TIDM, folder string) ([]string, error) {
+	var filenames []string
+	err := filepath.WalkDir(folder, func(foundPath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(folder, foundPath)
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			// skip excluded folders completely
+			if foundPath != folder && matchesPattern(options.Exclude, relPath, entry.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !t.IsIDLFile(foundPath) {
+			return nil
+		}
+		if len(options.Include) > 0 && !matchesPattern(options.Include, relPath, entry.Name()) {
+			return nil
+		}
+		if matchesPattern(options.Exclude, relPath, entry.Name()) {
+			return nil
+		}
+
+		// found an IDL file
+		filenames = append(filenames, foundPath)
+		return nil
+	})
+	return filenames, err
+}
+
+// matchesPattern returns true when one of the glob patterns matches the name, or the path relative to the scanned folder
+// Patterns use the syntax of filepath.Match, paths are matched with forward slashes.
+func matchesPattern(patterns []string, relPath string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
+		}
+		if matched, _ := path.Match(pattern, filepath.ToSlash(relPath)); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// newTIDM creates a TIDM with the extensions given with --ext
+func newTIDM() *tidm.TIDM {
+	t := tidm.NewTIDM()
+	for _, ext := range options.Extensions {
+		t.AddExtension(ext)
+	}
+	return t
+}
+
+// parseInputs parses the input documents into a TIDM
+// Diagnostics are written as given with --diagnostics-format, threft exits when the documents contain errors.
+func parseInputs() (*tidm.TIDM, tidm.Diagnostics) {
+	t := newTIDM()
+	in := findInputs(t)
+
+	// add include folders
+	for _, includeDir := range options.IncludeDirs {
+		t.AddIncludePath(includeDir)
+	}
+
+	// create document for each file found
+	for _, filename := range in.filenames {
+		// add document to TIDM
+		err := t.AddDocumentFileWithName(in.names[filename], filename)
+		if err != nil {
+			exitWithError(exitIOError, "Error adding document to TIDM: %s\n", err)
+		}
+	}
+	if in.readStdin {
+		err := t.AddDocument(tidm.DocumentName("stdin"), os.Stdin)
+		if err != nil {
+			exitWithError(exitIOError, "Error adding document from stdin to TIDM: %s\n", err)
+		}
+	}
+
+	// parse complete TIDM structure (each document, each target, each namespace)
+	logDebugf("Parsing %d documents.\n", len(t.Documents))
+	diags := t.Parse()
+	logDebugf("Parsed %d documents (including included documents), found %d errors and %d warnings.\n",
+		len(t.Documents), diags.Count(tidm.SeverityError), diags.Count(tidm.SeverityWarning))
+	err := writeDiagnostics(t, diags)
+	if err != nil {
+		exitWithError(exitIOError, "Error writing diagnostics: %s\n", err)
+	}
+	if diags.HasErrors() {
+		if options.DiagnosticsFormat != "text" {
+			os.Exit(exitInvalidIDL)
+		}
+		exitWithError(exitInvalidIDL, "\nFound %d errors.\n", diags.Count(tidm.SeverityError))
+	}
+	return t, diags
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// logLevel defines which messages are logged by threft
+// All messages are written to stderr, stdout is left for generator output, dumps and formatted documents.
+type logLevel int
+
+const (
+	logLevelError logLevel = iota // only errors, set with -q
+	logLevelInfo                  // progress messages (default)
+	logLevelDebug                 // detailed messages, set with -d
+)
+
+// currentLogLevel is set from the -d and -q options before a command is executed, see setupLogging()
+var currentLogLevel = logLevelInfo
+
+// setupLogging sets the log level from the -d and -q options
+func setupLogging() {
+	if options.Debugging && options.Quiet {
+		exitWithError(exitUsage, "%s", "Options -d and -q can not be used together.\n")
+	}
+	switch {
+	case options.Debugging:
+		currentLogLevel = logLevelDebug
+	case options.Quiet:
+		currentLogLevel = logLevelError
+	default:
+		currentLogLevel = logLevelInfo
+	}
+}
+
+func logf(level logLevel, format string, args ...interface{}) {
+	if level > currentLogLevel {
+		return
+	}
+	fmt.Fprintf(os.Stderr, format, args...)
+}
+
+// logErrorf logs an error message, errors are always logged
+func logErrorf(format string, args ...interface{}) {
+	logf(logLevelError, format, args...)
+}
+
+// logInfof logs a progress message, unless -q was given
+func logInfof(format string, args ...interface{}) {
+	logf(logLevelInfo, format, args...)
+}
+
+// logDebugf logs a debug message when -d was given
+func logDebugf(format string, args ...interface{}) {
+	logf(logLevelDebug, format, args...)
+}
@@ -1,210 +1,103 @@
 package main
 
 import (
-	"fmt"
 	"github.com/jessevdk/go-flags"
-	"github.com/threft/threft/tidm"
 	"os"
-	"os/exec"
-	"path/filepath"
-	"strings"
 )
 
+// options that apply to all commands
 var options struct {
-	Debugging  bool     `short:"d" long:"debug" description:"Enable logging of debug messages to StdOut"`
-	InputFiles []string `short:"i" long:"input" description:"Input folders/files"`
-	Generator  string   `short:"g" long:"gen" description:"Generator to use (for example: go, html), can include arguments for generator"`
-	OutputDir  string   `short:"o" long:"output" description:"Folder to generate code to"`
-	DumpTIDM   bool     `long:"dump-tidm" description:"Dumps TIDM structure to ./tidm_dump"`
+	Debugging         bool     `short:"d" long:"debug" description:"Enable logging of debug messages to stderr"`
+	Quiet             bool     `short:"q" long:"quiet" description:"Only log errors, progress messages are not shown"`
+	InputFiles        []string `short:"i" long:"input" description:"Input folders/files, folders are searched recursively. Use - to read a document from stdin, its includes are searched in the include folders (-I)"`
+	Include           []string `long:"include" description:"Only use files from input folders that match this glob pattern (name or path relative to the input folder), can be given multiple times"`
+	Exclude           []string `long:"exclude" description:"Skip files and folders within input folders that match this glob pattern (name or path relative to the input folder), can be given multiple times"`
+	IncludeDirs       []string `short:"I" long:"include-dir" description:"Folders to search for included documents"`
+	Extensions        []string `long:"ext" description:"Extra file extension for IDL files, in addition to .thrift and .threft, can be given multiple times"`
+	MaxErrors         int      `long:"max-errors" default:"20" description:"Maximum number of parse errors to print, 0 prints all errors"`
+	DiagnosticsFormat string   `long:"diagnostics-format" default:"text" choice:"text" choice:"json" choice:"sarif" description:"Format for errors and warnings: text, json (json lines) or sarif (SARIF 2.1.0 log)"`
+	DiagnosticsOutput string   `long:"diagnostics-output" value-name:"FILE" description:"File to write errors and warnings to, instead of stderr"`
 }
 
-func exitWithError(format string, args ...interface{}) {
-	fmt.Fprintf(os.Stderr, format, args...)
-	os.Exit(2)
-}
-
-func scanDir(path string) (filenames []string) {
-	// TODO if turning this function back into a recursive function,
-	// either pass an initial filenames slice as an argument, or
-	// append() all resulting slices into one.
-
-	// open given path
-	f, err := os.Open(path)
-	if err != nil {
-		fmt.Printf("Error opening '%s': %s\n", path, err)
-		return
-	}
+// exit codes, codes for problems found by threft itself follow sysexits.h
+// When the generator exits with a non-zero status, threft exits with that same status,
+// unless the status is one of these reserved codes, see generatorExitCode().
+const (
+	exitUsage             = 64  // invalid flags or arguments
+	exitInvalidIDL        = 65  // the input documents contain errors
+	exitIOError           = 74  // reading input, writing output or communicating with the generator failed
+	exitGeneratorReserved = 124 // the generator exited with a status that is reserved by threft
+	exitGeneratorFailed   = 125 // the generator could not be started or was terminated by a signal
+	exitGeneratorNotFound = 127 // the generator executable was not found in PATH
+)
 
-	// read fileInfo for all files/folders
-	fis, err := f.Readdir(-1)
-	if err != nil {
-		fmt.Printf("Error reading dir info on '%s': %s\n", path, err)
-		return
+// generatorExitCode returns the exit code for a generator that exited with given non-zero status
+// Statuses that threft uses itself are reported as exitGeneratorReserved, so they can't be mistaken for a problem found by threft.
+func generatorExitCode(status int) int {
+	switch status {
+	case exitUsage, exitInvalidIDL, exitIOError, exitGeneratorReserved, exitGeneratorFailed, exitGeneratorNotFound:
+		return exitGeneratorReserved
 	}
-	// loop through all files/folders
-	for _, fi := range fis {
-		foundFile := filepath.Join(path, fi.Name())
-		if fi.IsDir() {
-			// disabled, not doing recursive now..
-			// // recursive scan dir
-			// err := scanDir(foundFile)
-			// if err != nil {
-			//	return err
-			// }
-		} else if strings.HasSuffix(foundFile, ".threft") {
-			// found a .thrift file
-			filenames = append(filenames, foundFile)
-		}
-	}
-	return
+	return status
 }
 
-func main() {
-	args, err := flags.Parse(&options)
-	if err != nil {
-		flagError := err.(*flags.Error)
-		if flagError.Type == flags.ErrHelp {
-			return
-		}
-		if flagError.Type == flags.ErrUnknownFlag {
-			fmt.Println("Use --help to view all available options.")
-			os.Exit(1)
-		}
-		fmt.Printf("Error parsing flags: %s\n", err)
-		os.Exit(1)
-	}
+// exitCodesDescription is shown in the --help output
+const exitCodesDescription = `Exit codes:
+0    success
+64   invalid flags or arguments
+65   the input documents contain errors (or formatting differs, for fmt -l)
+74   I/O error (input, output or generator communication)
+124  the generator exited with 64, 65, 74, 124, 125 or 127
+125  the generator could not be started or was terminated by a signal
+127  the generator was not found
+Any other non-zero exit code is the exit status of the generator.`
+
+// exitWithError logs an error message and exits with given code
+func exitWithError(code int, format string, args ...interface{}) {
+	logErrorf(format, args...)
+	os.Exit(code)
+}
 
-	// check for unexpected arguments
+// checkArguments exits when a command was given arguments, all input is given with flags
+func checkArguments(args []string) {
 	if len(args) > 0 {
-		fmt.Printf("Unknown argument '%s'.\n", args[0])
-		os.Exit(1)
+		exitWithError(exitUsage, "Unknown argument '%s'.\n", args[0])
 	}
+}
 
-	// hardcode debugging enable
-	fmt.Println("Debug mode enabled, hardcoded in code.")
-	options.Debugging = true
-
-	outputDir, err := filepath.Abs(options.OutputDir)
+func main() {
+	parser := flags.NewParser(&options, flags.Default)
+	parser.LongDescription = exitCodesDescription
+
+	// add commands, see commands.go
+	parser.AddCommand("check", "Parse and validate documents",
+		"Parses and validates the input documents, and reports all errors and warnings. No code is generated.", &checkCommand{})
+	parser.AddCommand("generate", "Generate code with a generator",
+		"Parses the input documents and sends the TIDM as tidm-json to a generator (threft-gen-<name>).", &generateCommand{})
+	parser.AddCommand("dump", "Write the parsed TIDM",
+		"Parses the input documents and writes the TIDM as tidm-json or spew dump.", &dumpCommand{})
+	parser.AddCommand("fmt", "Format documents",
+		"Formats the input documents. Comments and line breaks are kept, indentation and spacing are normalized.", &fmtCommand{})
+	parser.AddCommand("generators", "List available generators",
+		"Lists the generators (threft-gen-<name> executables) that are found in PATH.", &generatorsCommand{})
+
+	// common setup, before the command is executed
+	parser.CommandHandler = func(command flags.Commander, args []string) error {
+		setupLogging()
+		logDebugf("Debug mode enabled.\n")
+
+		return command.Execute(args)
+	}
+
+	_, err := parser.Parse()
 	if err != nil {
-		exitWithError("Error getting absolute path for '%s': %s\n", options.OutputDir, err)
-	}
-
-	// create slice to store all filenames in..
-	filenames := []string{}
-
-	fmt.Println("Searching for thrift files and setting up documents.")
-	for _, filefolder := range options.InputFiles {
-		filefolder, err = filepath.Abs(filefolder)
-		if err != nil {
-			exitWithError("Error getting absolute path for '%s': %s\n", filefolder, err)
-		}
-
-		fi, err := os.Stat(filefolder)
-		if err != nil {
-			exitWithError("Error getting info on '%s': %s\n", filefolder, err)
-		}
-
-		if fi.IsDir() {
-			// do recursive file find
-			filenames = scanDir(filefolder)
-
-			// print findings
-			fmt.Printf("Found %d files in given path '%s'.\n", len(filenames), filefolder)
-			for _, filename := range filenames {
-				fmt.Printf("• %s\n", filename)
-			}
-			fmt.Println("")
-		} else {
-			// only one file given
-			// check if file is thrift file
-			if !strings.HasSuffix(filefolder, ".threft") {
-				exitWithError("Error: invalid file extension for '%s' (expected .threft).\n", filefolder)
-			}
-
-			// add filename to list
-			filenames = append(filenames, filefolder)
-		}
-	}
-
-	// create new TIDM
-	t := tidm.NewTIDM()
-
-	// create document for each file found
-	for _, filename := range filenames {
-		// assuming file name is correct and file is existing.
-		file, err := os.Open(filename)
-		if err != nil {
-			exitWithError("Error opening file. %s\n", err)
+		flagError, ok := err.(*flags.Error)
+		if ok && flagError.Type == flags.ErrHelp {
+			return
 		}
-		defer file.Close()
-
-		// add document to TIDM
-		lastPathSeperator := strings.LastIndex(filename, string(os.PathSeparator))
-		documentNameString := filename[lastPathSeperator+1:]
-		err = t.AddDocument(tidm.DocumentName(documentNameString), file)
-		if err != nil {
-			exitWithError("Error adding document to TIDM: %s\n", err)
+		if ok && (flagError.Type == flags.ErrUnknownFlag || flagError.Type == flags.ErrCommandRequired || flagError.Type == flags.ErrUnknownCommand) {
+			exitWithError(exitUsage, "%s", "Use --help to view all available commands and options.\n")
 		}
-		file.Close()
-	}
-
-	// parse complete TIDM structure (each document, each target, each namespace)
-	perr := t.Parse()
-	if perr != nil {
-		exitWithError("\nError at %s\n \t%s\n", perr.DocLine, perr.Message)
+		exitWithError(exitUsage, "Error parsing flags: %s\n", err)
 	}
-
-	// do a TIDM dump if requested by user
-	if options.DumpTIDM {
-		err = dumpTIDM(t)
-		if err != nil {
-			// TODO output a formatted message like in all other
-			// cases?
-			exitWithError("%s\n", err)
-		}
-	}
-
-	// get generator fields (possibly options)
-	genFields := strings.Fields(options.Generator)
-	if len(genFields) == 0 {
-		exitWithError("%s", "No generator given. Can not continue. Use -g to generate code.\n")
-	}
-
-	// prepare generator command
-	genCmd := exec.Command("threft-gen-"+genFields[0], genFields[1:]...)
-	genCmd.Dir = outputDir
-	genCmd.Stderr = os.Stderr
-	genCmd.Stdout = os.Stdout
-
-	// get stdinPipe to send json when process has started
-	stdinPipe, err := genCmd.StdinPipe()
-	if err != nil {
-		exitWithError("Error getting stdin pipe: %s\n", err)
-	}
-
-	// start generator
-	err = genCmd.Start()
-	if err != nil {
-		exitWithError("Error on starting generator: %s\n", err)
-	}
-
-	// write tidm-json to generator
-	err = t.EncodeTo(stdinPipe)
-	if err != nil {
-		exitWithError("Error writing data to generator: %s\n", err)
-	}
-
-	// close the stdinPipe
-	err = stdinPipe.Close()
-	if err != nil {
-		fmt.Printf("Error closing stdin pipe: %s\n", err)
-	}
-
-	// wait for generator to exit
-	err = genCmd.Wait()
-	if err != nil {
-		exitWithError("Error while running generator: %s\n", err)
-	}
-
-	fmt.Println("All done.")
 }
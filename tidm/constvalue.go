@@ -0,0 +1,405 @@
+package tidm
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// ConstValueKind defines the kind of a ConstValue
+type ConstValueKind string
+
+const (
+	ConstValueKindInt       = ConstValueKind("int")       // Int is set
+	ConstValueKindDouble    = ConstValueKind("double")    // Double is set
+	ConstValueKindString    = ConstValueKind("string")    // String is set
+	ConstValueKindBool      = ConstValueKind("bool")      // Bool is set
+	ConstValueKindList      = ConstValueKind("list")      // List is set, used for list and set values
+	ConstValueKindMap       = ConstValueKind("map")       // Map is set
+	ConstValueKindStruct    = ConstValueKind("struct")    // StructReference and Fields are set, used for struct, union and exception values
+	ConstValueKindConst     = ConstValueKind("const")     // ConstReference is set
+	ConstValueKindEnumValue = ConstValueKind("enumvalue") // EnumReference, EnumValue and Int are set
+
+	// constValueKindIdentifier is used for names that have not been resolved yet, it does not occur in a parsed TIDM.
+	constValueKindIdentifier = ConstValueKind("identifier")
+)
+
+// ConstValue is the value for a Const or the default value for a Field
+// A ConstValue is checked against and converted to the declared type when the TIDM is parsed,
+// e.g. an integer value for a double type is converted to a double value.
+type ConstValue struct {
+	Kind ConstValueKind
+	Name string `json:",omitempty"` // Name as written in the source, for const and enum value references
+
+	Int    int64            `json:",omitempty"`
+	Double float64          `json:",omitempty"`
+	String string           `json:",omitempty"`
+	Bool   bool             `json:",omitempty"`
+	List   []*ConstValue    `json:",omitempty"`
+	Map    []*ConstMapEntry `json:",omitempty"` // Entries in order of declaration
+
+	StructReference *Reference         `json:",omitempty"` // Struct, union or exception for a struct value
+	Fields          []*ConstFieldValue `json:",omitempty"` // Field values in order of declaration
+
+	ConstReference *ConstReference `json:",omitempty"` // Referenced const
+	EnumReference  *EnumReference  `json:",omitempty"` // Enum for an enum value
+	EnumValue      IdentifierName  `json:",omitempty"` // Name of the enum value within the enum
+
+	DocLine *DocLine // DocLine where the value is written
+}
+
+// ConstMapEntry is a single key/value pair within a map ConstValue
+type ConstMapEntry struct {
+	Key   *ConstValue
+	Value *ConstValue
+}
+
+// ConstFieldValue is the value for a single field within a struct ConstValue
+type ConstFieldValue struct {
+	Field IdentifierName // Name of the field
+	ID    int            // ID of the field
+	Value *ConstValue
+}
+
+// integerRanges contains the valid range for each integer base type
+var integerRanges = map[string][2]int64{
+	"byte": {math.MinInt8, math.MaxInt8},
+	"i8":   {math.MinInt8, math.MaxInt8},
+	"i16":  {math.MinInt16, math.MaxInt16},
+	"i32":  {math.MinInt32, math.MaxInt32},
+	"i64":  {math.MinInt64, math.MaxInt64},
+}
+
+// invalidConstValue creates a ParseError for a value that does not match the type
+func invalidConstValue(cv *ConstValue, ft *FieldType, reason string) *ParseError {
+	return &ParseError{
+		Type:    ParseErrorTypeInvalidConstValue,
+		Message: fmt.Sprintf("Invalid value for type '%s'. %s", ft, reason),
+		DocLine: cv.DocLine,
+	}
+}
+
+// checkConstValue checks the given value against given type and converts the value where required.
+// Names are resolved to the const or enum value they refer to.
+func (t *TIDM) checkConstValue(doc *Document, ft *FieldType, cv *ConstValue) *ParseError {
+	underlying, err := t.UnderlyingType(ft)
+	if err != nil {
+		return invalidConstValue(cv, ft, err.Error())
+	}
+
+	// references to other consts
+	if cv.Kind == ConstValueKindConst {
+		return t.checkConstReference(ft, cv, cv.ConstReference)
+	}
+	if cv.Kind == constValueKindIdentifier {
+		if ref := t.lookupIdentifier(doc, cv.Name); ref != nil && t.Documents[ref.DocumentName].Consts[ref.IdentifierName] != nil {
+			return t.checkConstReference(ft, cv, (*ConstReference)(ref))
+		}
+	}
+
+	switch underlying.Kind {
+	case FieldTypeKindBase:
+		return checkBaseConstValue(underlying, cv)
+
+	case FieldTypeKindNamed:
+		switch underlying.ReferenceKind {
+		case DefinitionKindEnum:
+			return t.checkEnumConstValue(doc, underlying, cv)
+		case DefinitionKindStruct, DefinitionKindUnion, DefinitionKindException:
+			return t.checkStructConstValue(doc, underlying, cv)
+		default:
+			return invalidConstValue(cv, ft, fmt.Sprintf("Constant values for %s types are not supported.", underlying.ReferenceKind))
+		}
+
+	case FieldTypeKindList, FieldTypeKindSet:
+		if cv.Kind != ConstValueKindList {
+			return invalidConstValue(cv, ft, "Expecting a list value.")
+		}
+		for _, elem := range cv.List {
+			perr := t.checkConstValue(doc, underlying.ElemType, elem)
+			if perr != nil {
+				return perr
+			}
+		}
+		return nil
+
+	case FieldTypeKindMap:
+		if cv.Kind != ConstValueKindMap {
+			return invalidConstValue(cv, ft, "Expecting a map value.")
+		}
+		for _, entry := range cv.Map {
+			perr := t.checkConstValue(doc, underlying.KeyType, entry.Key)
+			if perr != nil {
+				return perr
+			}
+			perr = t.checkConstValue(doc, underlying.ValueType, entry.Value)
+			if perr != nil {
+				return perr
+			}
+		}
+		return nil
+	}
+
+	return invalidConstValue(cv, ft, "Unknown type.")
+}
+
+// checkBaseConstValue checks and converts a value for a base type
+func checkBaseConstValue(ft *FieldType, cv *ConstValue) *ParseError {
+	switch ft.Name {
+	case "bool":
+		switch {
+		case cv.Kind == constValueKindIdentifier && (cv.Name == "true" || cv.Name == "false"):
+			cv.Bool = cv.Name == "true"
+		case cv.Kind == ConstValueKindInt && (cv.Int == 0 || cv.Int == 1):
+			cv.Bool = cv.Int == 1
+			cv.Int = 0
+		case cv.Kind == ConstValueKindBool:
+		default:
+			return invalidConstValue(cv, ft, "Expecting true, false, 0 or 1.")
+		}
+		cv.Kind = ConstValueKindBool
+		cv.Name = ""
+		return nil
+
+	case "byte", "i8", "i16", "i32", "i64":
+		if cv.Kind != ConstValueKindInt {
+			return invalidConstValue(cv, ft, "Expecting an integer value.")
+		}
+		valueRange := integerRanges[ft.Name]
+		if cv.Int < valueRange[0] || cv.Int > valueRange[1] {
+			return invalidConstValue(cv, ft, fmt.Sprintf("Value %d is out of range [%d, %d].", cv.Int, valueRange[0], valueRange[1]))
+		}
+		return nil
+
+	case "double":
+		switch cv.Kind {
+		case ConstValueKindInt:
+			cv.Kind = ConstValueKindDouble
+			cv.Double = float64(cv.Int)
+			cv.Int = 0
+		case ConstValueKindDouble:
+		default:
+			return invalidConstValue(cv, ft, "Expecting a numeric value.")
+		}
+		return nil
+
+	case "string", "binary":
+		if cv.Kind != ConstValueKindString {
+			return invalidConstValue(cv, ft, "Expecting a string literal.")
+		}
+		return nil
+	}
+
+	return invalidConstValue(cv, ft, "Unknown base type.")
+}
+
+// checkEnumConstValue checks and converts a value for an enum type.
+// Valid values are an enum value name (`Enum.VALUE`) or an integer that is defined within the enum.
+func (t *TIDM) checkEnumConstValue(doc *Document, ft *FieldType, cv *ConstValue) *ParseError {
+	e := t.Documents[ft.Reference.DocumentName].Enums[ft.Reference.IdentifierName]
+
+	switch cv.Kind {
+	case ConstValueKindInt:
+		for _, ev := range e.Values {
+			if int64(ev.Value) == cv.Int {
+				cv.Kind = ConstValueKindEnumValue
+				cv.EnumReference = (*EnumReference)(ft.Reference)
+				cv.EnumValue = ev.Identifier.Name
+				return nil
+			}
+		}
+		return invalidConstValue(cv, ft, fmt.Sprintf("Value %d is not defined for enum '%s'.", cv.Int, e.Identifier.Name))
+
+	case ConstValueKindEnumValue:
+		if Reference(*cv.EnumReference) == *ft.Reference {
+			return nil // already checked, e.g. through a const reference
+		}
+		return invalidConstValue(cv, ft, fmt.Sprintf("A value of enum '%s' is not a value of enum '%s'.", cv.EnumReference.IdentifierName, e.Identifier.Name))
+
+	case constValueKindIdentifier:
+		pos := strings.LastIndex(cv.Name, ".")
+		if pos > -1 {
+			ref := t.lookupIdentifier(doc, cv.Name[:pos])
+			if ref != nil && *ref == *ft.Reference {
+				for _, ev := range e.Values {
+					if string(ev.Identifier.Name) == cv.Name[pos+1:] {
+						cv.Kind = ConstValueKindEnumValue
+						cv.Int = int64(ev.Value)
+						cv.EnumReference = (*EnumReference)(ft.Reference)
+						cv.EnumValue = ev.Identifier.Name
+						return nil
+					}
+				}
+			}
+		}
+		return invalidConstValue(cv, ft, fmt.Sprintf("'%s' is not a value of enum '%s'.", cv.Name, e.Identifier.Name))
+	}
+
+	return invalidConstValue(cv, ft, "Expecting an enum value.")
+}
+
+// checkStructConstValue checks and converts a value for a struct, union or exception type.
+// Struct values are written as a map with field names as keys, e.g. `{"name": "foo", "count": 3}`.
+func (t *TIDM) checkStructConstValue(doc *Document, ft *FieldType, cv *ConstValue) *ParseError {
+	if cv.Kind == ConstValueKindStruct && *cv.StructReference == *ft.Reference {
+		return nil // already checked, e.g. through a const reference
+	}
+	if cv.Kind != ConstValueKindMap {
+		return invalidConstValue(cv, ft, fmt.Sprintf("Expecting a map with field names for %s '%s'.", ft.ReferenceKind, ft.Name))
+	}
+
+	definitionDoc := t.Documents[ft.Reference.DocumentName]
+	var fields []*Field
+	switch ft.ReferenceKind {
+	case DefinitionKindStruct:
+		fields = definitionDoc.Structs[ft.Reference.IdentifierName].Fields
+	case DefinitionKindUnion:
+		fields = definitionDoc.Unions[ft.Reference.IdentifierName].Fields
+	case DefinitionKindException:
+		fields = definitionDoc.Exceptions[ft.Reference.IdentifierName].Fields
+	}
+
+	if ft.ReferenceKind == DefinitionKindUnion && len(cv.Map) > 1 {
+		return invalidConstValue(cv, ft, "A union value can have at most one field set.")
+	}
+
+	fieldValues := make([]*ConstFieldValue, 0, len(cv.Map))
+	for _, entry := range cv.Map {
+		if entry.Key.Kind != ConstValueKindString {
+			return invalidConstValue(entry.Key, ft, "Expecting a field name as string literal.")
+		}
+		var field *Field
+		for _, f := range fields {
+			if string(f.Identifier.Name) == entry.Key.String {
+				field = f
+				break
+			}
+		}
+		if field == nil {
+			return invalidConstValue(entry.Key, ft, fmt.Sprintf("%s '%s' has no field '%s'.", ft.ReferenceKind, ft.Name, entry.Key.String))
+		}
+		for _, fv := range fieldValues {
+			if fv.Field == field.Identifier.Name {
+				return invalidConstValue(entry.Key, ft, fmt.Sprintf("Field '%s' is set more than once.", field.Identifier.Name))
+			}
+		}
+		perr := t.checkConstValue(doc, field.Type, entry.Value)
+		if perr != nil {
+			return perr
+		}
+		fieldValues = append(fieldValues, &ConstFieldValue{
+			Field: field.Identifier.Name,
+			ID:    field.ID,
+			Value: entry.Value,
+		})
+	}
+
+	cv.Kind = ConstValueKindStruct
+	cv.StructReference = ft.Reference
+	cv.Fields = fieldValues
+	cv.Map = nil
+	return nil
+}
+
+// checkConstReference checks that the value of the referenced const is valid for given type.
+// The referenced const is checked first, its value is not changed: a copy of the value is checked against the type.
+func (t *TIDM) checkConstReference(ft *FieldType, cv *ConstValue, ref *ConstReference) *ParseError {
+	c, _ := t.Const(*ref)
+	state, exists := t.constChecks[c]
+	switch {
+	case exists && !state.done:
+		return invalidConstValue(cv, ft, fmt.Sprintf("Const '%s' refers to itself.", cv.Name))
+	case t.checkConst(c) != nil:
+		// the error is reported for the referenced const itself
+		cv.Kind = ConstValueKindConst
+		cv.ConstReference = ref
+		return nil
+	}
+
+	value := c.Value.copy()
+	value.DocLine = cv.DocLine
+	if perr := t.checkConstValue(t.Documents[ref.DocumentName], ft, value); perr != nil {
+		return invalidConstValue(cv, ft, fmt.Sprintf("Const '%s' is not a valid value: %s", cv.Name, perr.Message))
+	}
+
+	cv.Kind = ConstValueKindConst
+	cv.ConstReference = ref
+	return nil
+}
+
+// constCheck is the state of checking a single const, see checkConst()
+type constCheck struct {
+	done bool        // false while the const is being checked
+	perr *ParseError // error found for the const
+}
+
+// checkConst checks the value of given const against its type, each const is checked only once.
+// Consts that are referenced by the value are checked before the value itself.
+func (t *TIDM) checkConst(c *Const) *ParseError {
+	if state, exists := t.constChecks[c]; exists {
+		return state.perr
+	}
+	state := &constCheck{}
+	t.constChecks[c] = state
+	state.perr = t.checkConstValue(t.Documents[c.Identifier.DocLine.DocumentName], c.Type, c.Value)
+	state.done = true
+	return state.perr
+}
+
+// copy returns a deep copy of the value, references to definitions are shared
+func (cv *ConstValue) copy() *ConstValue {
+	cp := *cv
+	if cv.List != nil {
+		cp.List = make([]*ConstValue, len(cv.List))
+		for i, elem := range cv.List {
+			cp.List[i] = elem.copy()
+		}
+	}
+	if cv.Map != nil {
+		cp.Map = make([]*ConstMapEntry, len(cv.Map))
+		for i, entry := range cv.Map {
+			cp.Map[i] = &ConstMapEntry{Key: entry.Key.copy(), Value: entry.Value.copy()}
+		}
+	}
+	if cv.Fields != nil {
+		cp.Fields = make([]*ConstFieldValue, len(cv.Fields))
+		for i, fv := range cv.Fields {
+			cp.Fields[i] = &ConstFieldValue{Field: fv.Field, ID: fv.ID, Value: fv.Value.copy()}
+		}
+	}
+	return &cp
+}
+
+// checkConstValues checks all const values and field default values against their types
+// Consts are checked in order of declaration, a const that is referenced is checked before the referencing value.
+func (t *TIDM) checkConstValues() (perrs ParseErrors) {
+	t.constChecks = make(map[*Const]*constCheck)
+	for _, doc := range t.sortedDocuments() {
+		consts := make([]*Const, 0, len(doc.Consts))
+		for _, c := range doc.Consts {
+			consts = append(consts, c)
+		}
+		sort.Slice(consts, func(i, j int) bool {
+			return docLineLess(consts[i].Identifier.DocLine, consts[j].Identifier.DocLine)
+		})
+		for _, c := range consts {
+			if perr := t.checkConst(c); perr != nil {
+				perrs = append(perrs, perr)
+			}
+		}
+	}
+	for _, doc := range t.sortedDocuments() {
+		for _, fields := range doc.fieldLists() {
+			for _, f := range fields {
+				if f.DefaultValue == nil {
+					continue
+				}
+				if perr := t.checkConstValue(doc, f.Type, f.DefaultValue); perr != nil {
+					perrs = append(perrs, perr)
+				}
+			}
+		}
+	}
+	return perrs
+}
@@ -0,0 +1,91 @@
+package tidm
+
+import (
+	"strings"
+	"testing"
+)
+
+// parseTestDocument parses a TIDM with a single document named test.thrift
+func parseTestDocument(source string) (*TIDM, Diagnostics) {
+	t := NewTIDM()
+	t.AddDocument(DocumentName("test.thrift"), strings.NewReader(source))
+	return t, t.Parse()
+}
+
+func TestCheckConstValues(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		err    string // part of the expected error message, empty when the source is valid
+	}{
+		{"int", "const i32 X = 1", ""},
+		{"int out of range", "const byte X = 300", "out of range"},
+		{"int to double", "const double X = 1", ""},
+		{"double to int", "const i32 X = 1.5", "Expecting an integer value."},
+		{"bool", "const bool X = true\nconst bool Y = 0", ""},
+		{"string", `const string X = "foo"`, ""},
+		{"enum value", "enum E { A, B }\nconst E X = E.B", ""},
+		{"enum integer", "enum E { A, B }\nconst E X = 1", ""},
+		{"enum undefined integer", "enum E { A, B }\nconst E X = 5", "not defined for enum"},
+		{"enum value of other enum", "enum E { A }\nenum F { A }\nconst E X = F.A", "not a value of enum"},
+		{"list", "const list<i32> X = [1, 2, 3]", ""},
+		{"list element", `const list<i32> X = [1, "2"]`, "Expecting an integer value."},
+		{"map", `const map<string, i32> X = {"a": 1}`, ""},
+		{"struct", "struct S { 1: i32 a, 2: string b }\nconst S X = {\"a\": 1, \"b\": \"foo\"}", ""},
+		{"struct unknown field", "struct S { 1: i32 a }\nconst S X = {\"c\": 1}", "has no field 'c'"},
+		{"union with two fields", "union U { 1: i32 a, 2: i32 b }\nconst U X = {\"a\": 1, \"b\": 2}", "at most one field"},
+
+		// references to other consts
+		{"reference", "const i32 A = 1\nconst i32 B = A", ""},
+		{"reference chain", "const i32 A = 1\nconst i32 B = A\nconst i32 C = B", ""},
+		{"reference before declaration", "const i32 C = B\nconst i32 B = A\nconst i32 A = 1", ""},
+		{"reference to int as double", "const i32 A = 1\nconst double B = A", ""},
+		{"reference to double as int", "const double D = 1\nconst i32 X = D", "Expecting an integer value."},
+		{"reference out of range", "const i64 A = 1000\nconst byte B = A", "out of range"},
+		{"reference to enum list", "enum E { A, B }\nconst list<E> L = [E.A]\nconst list<E> L2 = L", ""},
+		{"reference to enum const", "enum E { A, B }\nconst E A1 = E.A\nconst E A2 = A1", ""},
+		{"reference to enum of other type", "enum E { A }\nenum F { A }\nconst E X = E.A\nconst F Y = X", "not a value of enum"},
+		{"reference in list", "const i32 A = 1\nconst list<i32> L = [A, 2]", ""},
+		{"reference to struct in list", "struct S { 1: i32 a }\nconst S X = {\"a\": 1}\nconst list<S> L = [X]", ""},
+		{"reference to itself", "const i32 A = A", "refers to itself"},
+		{"reference cycle", "const i32 A = B\nconst i32 B = A", "refers to itself"},
+		{"field default reference", "const i32 A = 1\nstruct S { 1: i32 a = A }", ""},
+		{"field default reference of wrong type", "const string A = \"foo\"\nstruct S { 1: i32 a = A }", "Expecting an integer value."},
+	}
+
+	for _, test := range tests {
+		// consts are stored in a map, parse several times to catch results that depend on map order
+		for i := 0; i < 20; i++ {
+			_, diags := parseTestDocument(test.source)
+			switch {
+			case test.err == "" && diags.HasErrors():
+				t.Fatalf("%s: unexpected errors: %v", test.name, diags)
+			case test.err != "" && diags.Count(SeverityError) != 1:
+				t.Fatalf("%s: expected 1 error containing %q, got: %v", test.name, test.err, diags)
+			case test.err != "" && !strings.Contains(diags[0].Message, test.err):
+				t.Fatalf("%s: expected error containing %q, got: %v", test.name, test.err, diags)
+			}
+		}
+	}
+}
+
+func TestCheckConstReferenceKeepsValue(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		tidm, diags := parseTestDocument("const list<i64> L = [1, 2]\nconst list<double> LD = L")
+		if diags.HasErrors() {
+			t.Fatalf("unexpected errors: %v", diags)
+		}
+		doc := tidm.Documents["test.thrift"]
+
+		// the referenced value is not converted to the type of the referencing const
+		for _, elem := range doc.Consts["L"].Value.List {
+			if elem.Kind != ConstValueKindInt {
+				t.Fatalf("expected elements of L to be %s, got %s", ConstValueKindInt, elem.Kind)
+			}
+		}
+		ld := doc.Consts["LD"].Value
+		if ld.Kind != ConstValueKindConst || ld.ConstReference == nil || ld.ConstReference.IdentifierName != "L" {
+			t.Fatalf("expected LD to reference L, got %+v", ld)
+		}
+	}
+}
@@ -2,51 +2,103 @@ package tidm
 
 //++ TODO: Add AddConst, AddTypedef, etc. methods that check if identifier is unique and then add the type and its identifier
 
-type FieldType string
-type DefinitionType string
+// Definitions, enum values, fields and functions have a Doc field which contains the doc comment (`/** ... */`)
+// preceding them in the source, without comment markers and leading asterisks. Doc is empty when there is no doc comment.
+
+// DefinitionKind defines the kind of a definition
+type DefinitionKind string
+
+const (
+	DefinitionKindConst     = DefinitionKind("const")
+	DefinitionKindTypedef   = DefinitionKind("typedef")
+	DefinitionKindEnum      = DefinitionKind("enum")
+	DefinitionKindStruct    = DefinitionKind("struct")
+	DefinitionKindUnion     = DefinitionKind("union")
+	DefinitionKindException = DefinitionKind("exception")
+	DefinitionKindService   = DefinitionKind("service")
+)
 
 type Typedef struct {
 	Identifier *Identifier
-	Type       DefinitionType
+	Doc        string
+	Type       *FieldType
 }
 
 type Const struct {
-	Type       FieldType
+	Type       *FieldType
 	Identifier *Identifier
-	Value      interface{}
+	Doc        string
+	Value      *ConstValue
 }
 
 type Enums struct {
 	Identifier *Identifier
-	Values     map[string]int
+	Doc        string
+	Values     []*EnumValue // Values in order of declaration
+}
+
+// EnumValue is a single named value within an Enums definition
+type EnumValue struct {
+	Identifier *Identifier
+	Doc        string
+	Value      int
 }
 
-//++ TODO
 type Struct struct {
 	Identifier *Identifier
+	Doc        string
+	Fields     []*Field // Fields in order of declaration
+}
 
-	//++ TODO: fields have their own type (structs) with data and DocLine to identify the field-specific doc and line
+// FieldRequiredness defines wether a field must be set
+type FieldRequiredness string
 
-	Foo string
-	Bar int
+const (
+	FieldRequirednessDefault  = FieldRequiredness("default")
+	FieldRequirednessRequired = FieldRequiredness("required")
+	FieldRequirednessOptional = FieldRequiredness("optional")
+)
+
+// Field is a single field within a Struct, Union or Exception, or an argument or throws entry for a Function
+type Field struct {
+	ID           int               // Field id. When no id was given in the source, a negative id is assigned.
+	Requiredness FieldRequiredness // Requiredness of the field
+	Type         *FieldType        // Type of the field
+	Identifier   *Identifier       // Field name and DocLine
+	Doc          string            // Doc comment for the field
+	DefaultValue *ConstValue       // Default value, nil when no default value was given
+	DocLine      *DocLine          // Range of the complete field declaration
 }
 
-//++ TODO
-type Exception struct {
+// Union is defined like a Struct, but at most one of its fields is set at any time.
+// Fields of a union cannot be required.
+type Union struct {
 	Identifier *Identifier
-
-	//++ TODO: fields have their own type (structs) with data and DocLine to identify the field-specific doc and line
-
-	Foo string
-	Bar int
+	Doc        string
+	Fields     []*Field // Fields in order of declaration
 }
 
-//++ TODO
-type Service struct {
+// Exception is defined like a Struct, but is to be used as error type by generators
+type Exception struct {
 	Identifier *Identifier
+	Doc        string
+	Fields     []*Field // Fields in order of declaration
+}
 
-	//++ TODO: fields have their own type (structs) with data and DocLine to identify the field-specific doc and line
+type Service struct {
+	Identifier       *Identifier
+	Doc              string
+	Extends          IdentifierName    // Name of the extended service, empty when service does not extend another service
+	ExtendsReference *ServiceReference // Reference to the extended service, nil when service does not extend another service
+	Functions        []*Function       // Functions in order of declaration
+}
 
-	Foo string
-	Bar int
+// Function is a single function within a Service
+type Function struct {
+	Identifier *Identifier
+	Doc        string
+	Oneway     bool       // Oneway functions do not return and cannot throw
+	ReturnType *FieldType // Return type, nil when the function returns nothing (void)
+	Arguments  []*Field   // Arguments in order of declaration
+	Throws     []*Field   // Exceptions that can be thrown by the function
 }
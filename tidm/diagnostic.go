@@ -0,0 +1,133 @@
+package tidm
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Severity defines how serious a Diagnostic is
+type Severity string
+
+const (
+	SeverityError   = Severity("error")   // the TIDM is invalid
+	SeverityWarning = Severity("warning") // the TIDM is valid, but part of the source was ignored or is likely a mistake
+	SeverityInfo    = Severity("info")    // informational message about the source
+)
+
+// DiagnosticCode is a stable identifier for a kind of Diagnostic
+// Codes for errors start with E, each ParseErrorType has its own code, see errorCodes.
+// Codes for warnings start with W, codes for info messages start with I.
+type DiagnosticCode string
+
+const (
+	DiagnosticCodeInvalidNamespaceHeader = DiagnosticCode("W001") // namespace header is invalid and was ignored
+	DiagnosticCodeIgnoredCppInclude      = DiagnosticCode("I001") // cpp_include is not supported by threft and was ignored
+)
+
+// Diagnostic is a single message about a document, found while parsing the TIDM
+type Diagnostic struct {
+	Severity Severity
+	Code     DiagnosticCode
+	Message  string
+	DocLine  *DocLine   // DocLine the diagnostic applies to, nil when it applies to the TIDM as a whole
+	Related  []*DocLine `json:",omitempty"` // Other DocLines involved
+}
+
+func (d *Diagnostic) String() string {
+	if d.DocLine == nil {
+		return fmt.Sprintf("%s %s: %s", d.Severity, d.Code, d.Message)
+	}
+	return fmt.Sprintf("%s: %s %s: %s", d.DocLine, d.Severity, d.Code, d.Message)
+}
+
+// Diagnostics is a list of diagnostics, sorted by document and line
+type Diagnostics []*Diagnostic
+
+// HasErrors returns true when the list contains a Diagnostic with SeverityError
+func (diags Diagnostics) HasErrors() bool {
+	return diags.Count(SeverityError) > 0
+}
+
+// Count returns the number of diagnostics with given severity
+func (diags Diagnostics) Count(severity Severity) int {
+	var count int
+	for _, d := range diags {
+		if d.Severity == severity {
+			count++
+		}
+	}
+	return count
+}
+
+// sort sorts the diagnostics by document name and position, diagnostics without DocLine are placed first
+func (diags Diagnostics) sort() {
+	sort.SliceStable(diags, func(i, j int) bool {
+		return docLineLess(diags[i].DocLine, diags[j].DocLine)
+	})
+}
+
+// errorCodes contains the DiagnosticCode for each ParseErrorType
+// Codes are used to filter diagnostics in CI and editors: a code must never be changed or reused for another ParseErrorType.
+// New ParseErrorTypes get the next free code.
+var errorCodes = map[ParseErrorType]DiagnosticCode{
+	ParseErrorTypeUnexpectedKeyword:          "E000",
+	ParseErrorTypeAlreadyParsed:              "E001",
+	ParseErrorTypeNoDefinitionsFound:         "E002",
+	ParseErrorTypeInvalidTypedefDefinition:   "E003",
+	ParseErrorTypeInvalidConstDefinition:     "E004",
+	ParseErrorTypeInvalidIdentifier:          "E005",
+	ParseErrorTypeDuplicateIdentifier:        "E006",
+	ParseErrorTypeUnexpectedError:            "E007",
+	ParseErrorNotSupported:                   "E008",
+	ParseErrorTypeInvalidEnumDefinition:      "E009",
+	ParseErrorTypeUnexpectedEndOfDocument:    "E010",
+	ParseErrorTypeInvalidStructDefinition:    "E011",
+	ParseErrorTypeInvalidFieldDefinition:     "E012",
+	ParseErrorTypeInvalidExceptionDefinition: "E013",
+	ParseErrorTypeInvalidServiceDefinition:   "E014",
+	ParseErrorTypeInvalidFunctionDefinition:  "E015",
+	ParseErrorTypeInvalidUnionDefinition:     "E016",
+	ParseErrorTypeInvalidToken:               "E017",
+	ParseErrorTypeUnexpectedToken:            "E018",
+	ParseErrorTypeInvalidInclude:             "E019",
+	ParseErrorTypeUnknownIdentifier:          "E020",
+	ParseErrorTypeIncludeCycle:               "E021",
+	ParseErrorTypeTypedefCycle:               "E022",
+	ParseErrorTypeInvalidConstValue:          "E023",
+}
+
+// diagnosticCodeUnknownError is used for a ParseErrorType that has no code in errorCodes
+const diagnosticCodeUnknownError = DiagnosticCode("E999")
+
+// Code returns the stable DiagnosticCode for the ParseErrorType
+func (pet ParseErrorType) Code() DiagnosticCode {
+	code, exists := errorCodes[pet]
+	if !exists {
+		return diagnosticCodeUnknownError
+	}
+	return code
+}
+
+// Diagnostic returns the ParseError as Diagnostic with SeverityError
+func (pe *ParseError) Diagnostic() *Diagnostic {
+	return &Diagnostic{
+		Severity: SeverityError,
+		Code:     pe.Type.Code(),
+		Message:  pe.Message,
+		DocLine:  pe.DocLine,
+		Related:  pe.Related,
+	}
+}
+
+// docLineLess orders DocLines by document name, line and column, a nil DocLine is placed first
+func docLineLess(a, b *DocLine) bool {
+	switch {
+	case a == nil || b == nil:
+		return a == nil && b != nil
+	case a.DocumentName != b.DocumentName:
+		return a.DocumentName < b.DocumentName
+	case a.Line != b.Line:
+		return a.Line < b.Line
+	}
+	return a.Column < b.Column
+}
@@ -0,0 +1,56 @@
+package tidm
+
+import (
+	"testing"
+)
+
+// TestDiagnosticCodes pins the diagnostic codes, codes must not change when types are added or reordered
+func TestDiagnosticCodes(t *testing.T) {
+	codes := map[ParseErrorType]DiagnosticCode{
+		ParseErrorTypeUnexpectedKeyword:          "E000",
+		ParseErrorTypeAlreadyParsed:              "E001",
+		ParseErrorTypeNoDefinitionsFound:         "E002",
+		ParseErrorTypeInvalidTypedefDefinition:   "E003",
+		ParseErrorTypeInvalidConstDefinition:     "E004",
+		ParseErrorTypeInvalidIdentifier:          "E005",
+		ParseErrorTypeDuplicateIdentifier:        "E006",
+		ParseErrorTypeUnexpectedError:            "E007",
+		ParseErrorNotSupported:                   "E008",
+		ParseErrorTypeInvalidEnumDefinition:      "E009",
+		ParseErrorTypeUnexpectedEndOfDocument:    "E010",
+		ParseErrorTypeInvalidStructDefinition:    "E011",
+		ParseErrorTypeInvalidFieldDefinition:     "E012",
+		ParseErrorTypeInvalidExceptionDefinition: "E013",
+		ParseErrorTypeInvalidServiceDefinition:   "E014",
+		ParseErrorTypeInvalidFunctionDefinition:  "E015",
+		ParseErrorTypeInvalidUnionDefinition:     "E016",
+		ParseErrorTypeInvalidToken:               "E017",
+		ParseErrorTypeUnexpectedToken:            "E018",
+		ParseErrorTypeInvalidInclude:             "E019",
+		ParseErrorTypeUnknownIdentifier:          "E020",
+		ParseErrorTypeIncludeCycle:               "E021",
+		ParseErrorTypeTypedefCycle:               "E022",
+		ParseErrorTypeInvalidConstValue:          "E023",
+	}
+	for pet, code := range codes {
+		if got := pet.Code(); got != code {
+			t.Errorf("ParseErrorType %d: expected code %s, got %s", pet, code, got)
+		}
+	}
+
+	// each ParseErrorType has its own code
+	if len(errorCodes) != len(codes) {
+		t.Errorf("expected %d error codes, got %d: add new codes to this test", len(codes), len(errorCodes))
+	}
+	seen := make(map[DiagnosticCode]ParseErrorType)
+	for pet, code := range errorCodes {
+		if other, exists := seen[code]; exists {
+			t.Errorf("code %s is used for ParseErrorType %d and %d", code, other, pet)
+		}
+		seen[code] = pet
+	}
+
+	if DiagnosticCodeInvalidNamespaceHeader != "W001" || DiagnosticCodeIgnoredCppInclude != "I001" {
+		t.Errorf("warning and info codes changed")
+	}
+}
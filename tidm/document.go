@@ -5,24 +5,32 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
+	"path"
+	"path/filepath"
 	"strings"
 )
 
 var (
 	ErrDocumentWithNameExists = errors.New("A document with given name exists already in this TIDM")
+	ErrDocumentWithPathExists = errors.New("A document for given file exists already in this TIDM")
 )
 
 // DocumentName represents any documentname, this can be anything (filename, random string, "stdin", etc.)
 type DocumentName string
 
-// DocLine is a reference to a thrift idl document and the source line number
+// DocLine is a reference to a thrift idl document and a range within its source
+// Lines and columns start at 0, columns are counted in runes. The range ends before EndColumn on EndLine.
 type DocLine struct {
 	DocumentName DocumentName
-	Line         int
+	Line         int // start line
+	Column       int // start column
+	EndLine      int
+	EndColumn    int
 }
 
 func (dl DocLine) String() string {
-	return fmt.Sprintf("%s:%d", dl.DocumentName, dl.Line+1)
+	return fmt.Sprintf("%s:%d:%d", dl.DocumentName, dl.Line+1, dl.Column+1)
 }
 
 // Document can be any thrift definition language source (filename, StdIn, etc.)
@@ -32,19 +40,36 @@ type Document struct {
 
 	Name               DocumentName                 // The name of this document.
 	NamespaceForTarget map[TargetName]NamespaceName // List of namespaces (per target) this document describes
+	Includes           map[string]DocumentName      // Included documents, by the prefix used to reference their definitions
 
 	// Definitions in this document
 	Consts     map[IdentifierName]*Const
 	Typedefs   map[IdentifierName]*Typedef
 	Enums      map[IdentifierName]*Enums
 	Structs    map[IdentifierName]*Struct
+	Unions     map[IdentifierName]*Union
 	Exceptions map[IdentifierName]*Exception
 	Services   map[IdentifierName]*Service
 
 	// source & parse management
-	identifiers          map[IdentifierName]*Identifier // list of identifiers used in this document, used to check uniqueness
-	lines                []string                       // All source lines for this document.
-	lastParsedLineNumber int                            // line number of the last parsed line. Used by nextMeaningfulLine().
+	identifiers map[IdentifierName]*Identifier // list of identifiers used in this document, used to check uniqueness
+	lines       []string                       // All source lines for this document.
+	tokens      []*token                       // All tokens for this document, see tokenize().
+	tokenIndex  int                            // index of the next token to be parsed.
+
+	// include management
+	path              string              // path to the source file, empty when the document was not read from a file
+	includeStatements []*includeStatement // include statements in this document, see TIDM.loadIncludes()
+
+	// warnings and info messages found while parsing
+	diagnostics Diagnostics
+}
+
+// includeStatement is an include header as found in a document
+type includeStatement struct {
+	filename     string       // filename as written in the include statement
+	docLine      *DocLine     // DocLine for the include statement
+	documentName DocumentName // name of the included document, set when the include has been loaded
 }
 
 func (t *TIDM) newDocument(name DocumentName) (*Document, error) {
@@ -59,18 +84,21 @@ func (t *TIDM) newDocument(name DocumentName) (*Document, error) {
 
 		Name:               name,
 		NamespaceForTarget: make(map[TargetName]NamespaceName),
+		Includes:           make(map[string]DocumentName),
 
 		Consts:     make(map[IdentifierName]*Const),
 		Typedefs:   make(map[IdentifierName]*Typedef),
 		Enums:      make(map[IdentifierName]*Enums),
 		Structs:    make(map[IdentifierName]*Struct),
+		Unions:     make(map[IdentifierName]*Union),
 		Exceptions: make(map[IdentifierName]*Exception),
 		Services:   make(map[IdentifierName]*Service),
 
-		identifiers:          make(map[IdentifierName]*Identifier),
-		lastParsedLineNumber: -1,
+		identifiers: make(map[IdentifierName]*Identifier),
 	}
-	doc.NamespaceForTarget[TargetNameDefault] = NamespaceName(strings.Replace(string(name), ".threft", "", -1))
+	// default namespace is the base name of the document without IDL file extension
+	baseName := path.Base(string(name))
+	doc.NamespaceForTarget[TargetNameDefault] = NamespaceName(strings.TrimSuffix(baseName, t.idlExtension(baseName)))
 	t.Documents[name] = doc
 
 	// set max doc filename length
@@ -102,8 +130,9 @@ func (t *TIDM) newDocumentFromReader(name DocumentName, sourceInput io.Reader) (
 				}
 				break
 			}
-			fmt.Printf("Error while reading line %d from sourceInput %s. %s\n", len(doc.lines)+1, name, err)
-			break
+			// the document is incomplete, it is removed from the TIDM again
+			delete(t.Documents, name)
+			return nil, fmt.Errorf("Error while reading line %d from sourceInput %s. %s", len(doc.lines)+1, name, err)
 		}
 	addLine:
 		doc.lines = append(doc.lines, line)
@@ -112,3 +141,99 @@ func (t *TIDM) newDocumentFromReader(name DocumentName, sourceInput io.Reader) (
 	// all done
 	return doc, nil
 }
+
+// newDocumentFromFile reads a document from given file, the base filename is used when name is empty
+func (t *TIDM) newDocumentFromFile(name DocumentName, filename string) (*Document, error) {
+	filename, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	// check if the file was added before
+	if _, exists := t.documentPaths[filename]; exists {
+		return nil, ErrDocumentWithPathExists
+	}
+
+	// open file
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	// read document
+	if len(name) == 0 {
+		name = DocumentName(filepath.Base(filename))
+	}
+	doc, err := t.newDocumentFromReader(name, file)
+	if err == ErrDocumentWithNameExists {
+		existing := t.Documents[name]
+		if len(existing.path) > 0 {
+			return nil, fmt.Errorf("%w: cannot add '%s' as '%s', the name is used by '%s'", err, filename, name, existing.path)
+		}
+		return nil, fmt.Errorf("%w: cannot add '%s' as '%s'", err, filename, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	doc.path = filename
+	t.documentPaths[filename] = doc
+
+	// all done
+	return doc, nil
+}
+
+// definitionKind returns the kind of definition for given identifier, or an empty DefinitionKind when it is not defined.
+func (doc *Document) definitionKind(name IdentifierName) DefinitionKind {
+	switch {
+	case doc.Consts[name] != nil:
+		return DefinitionKindConst
+	case doc.Typedefs[name] != nil:
+		return DefinitionKindTypedef
+	case doc.Enums[name] != nil:
+		return DefinitionKindEnum
+	case doc.Structs[name] != nil:
+		return DefinitionKindStruct
+	case doc.Unions[name] != nil:
+		return DefinitionKindUnion
+	case doc.Exceptions[name] != nil:
+		return DefinitionKindException
+	case doc.Services[name] != nil:
+		return DefinitionKindService
+	default:
+		return ""
+	}
+}
+
+// fieldLists returns all lists of fields in this document (struct, union and exception fields, function arguments and throws)
+func (doc *Document) fieldLists() [][]*Field {
+	var lists [][]*Field
+	for _, s := range doc.Structs {
+		lists = append(lists, s.Fields)
+	}
+	for _, u := range doc.Unions {
+		lists = append(lists, u.Fields)
+	}
+	for _, e := range doc.Exceptions {
+		lists = append(lists, e.Fields)
+	}
+	for _, s := range doc.Services {
+		for _, f := range s.Functions {
+			lists = append(lists, f.Arguments, f.Throws)
+		}
+	}
+	return lists
+}
+
+// SourceLine returns the source text for given line (starting at 0), without line ending
+func (doc *Document) SourceLine(line int) (string, bool) {
+	if line < 0 || line >= len(doc.lines) {
+		return "", false
+	}
+	return strings.TrimRight(doc.lines[line], "\r\n"), true
+}
+
+// Path returns the path to the source file for this document, or an empty string when the document was not read from a file
+func (doc *Document) Path() string {
+	return doc.path
+}
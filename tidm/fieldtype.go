@@ -0,0 +1,55 @@
+package tidm
+
+import (
+	"fmt"
+)
+
+// FieldTypeKind defines the kind of a FieldType
+type FieldTypeKind string
+
+const (
+	FieldTypeKindBase  = FieldTypeKind("base")  // one of the BaseTypes
+	FieldTypeKindNamed = FieldTypeKind("named") // named type, referencing a typedef, enum, struct, union or exception
+	FieldTypeKindList  = FieldTypeKind("list")  // list<ElemType>
+	FieldTypeKindSet   = FieldTypeKind("set")   // set<ElemType>
+	FieldTypeKindMap   = FieldTypeKind("map")   // map<KeyType,ValueType>
+)
+
+// BaseTypes contains the names of all base types
+var BaseTypes = []string{"bool", "byte", "i8", "i16", "i32", "i64", "double", "string", "binary"}
+
+// isBaseType returns true when the given name is a base type
+func isBaseType(name string) bool {
+	for _, baseType := range BaseTypes {
+		if name == baseType {
+			return true
+		}
+	}
+	return false
+}
+
+// FieldType is the type for a typedef, const, field or function return value
+type FieldType struct {
+	Kind      FieldTypeKind
+	Name      string     // Name of the base type or named type, empty for container types
+	ElemType  *FieldType // Element type for list and set
+	KeyType   *FieldType // Key type for map
+	ValueType *FieldType // Value type for map
+	DocLine   *DocLine   // DocLine where the type is written
+
+	// Definition referenced by a named type, set when the TIDM is parsed
+	Reference     *Reference
+	ReferenceKind DefinitionKind
+}
+
+// String returns the FieldType as it would be written in a thrift idl document
+func (ft *FieldType) String() string {
+	switch ft.Kind {
+	case FieldTypeKindList, FieldTypeKindSet:
+		return fmt.Sprintf("%s<%s>", ft.Kind, ft.ElemType)
+	case FieldTypeKindMap:
+		return fmt.Sprintf("map<%s,%s>", ft.KeyType, ft.ValueType)
+	default:
+		return ft.Name
+	}
+}
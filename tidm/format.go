@@ -0,0 +1,136 @@
+package tidm
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// formatIndent is the indentation for each level of nesting in a formatted document
+const formatIndent = "  "
+
+// Format formats the source of a document that was added to this TIDM.
+// Line breaks and comments are kept, indentation and the spacing between tokens are normalized
+// and consecutive blank lines are reduced to one.
+// The document must be syntactically valid, included documents are not loaded and names are not resolved.
+func (t *TIDM) Format(name DocumentName) ([]byte, Diagnostics) {
+	doc, exists := t.Documents[name]
+	if !exists {
+		return nil, Diagnostics{(&ParseError{
+			Type:    ParseErrorTypeUnexpectedError,
+			Message: fmt.Sprintf("Document '%s' was not added to this TIDM", name),
+		}).Diagnostic()}
+	}
+
+	// check syntax, formatting an invalid document could change its meaning
+	perrs := doc.tokenize()
+	if len(perrs) == 0 {
+		perrs = append(perrs, doc.parseDocumentHeaders()...)
+		perrs = append(perrs, doc.parseDocumentDefinitions()...)
+	}
+	if len(perrs) > 0 {
+		var diags Diagnostics
+		for _, perr := range perrs {
+			diags = append(diags, perr.Diagnostic())
+		}
+		diags.sort()
+		return nil, diags
+	}
+
+	return formatTokens(doc.tokens), nil
+}
+
+// formatTokens writes the tokens and their comments, keeping the line breaks from the source
+func formatTokens(tokens []*token) []byte {
+	var buf bytes.Buffer
+	var prev *token // previous token written
+	lastLine := -1  // source line at the end of the last token or comment written
+	depth := 0      // number of unclosed brackets
+	afterComment := false
+
+	// newLine starts a new line for an element that starts at given source line, keeping a single blank line
+	newLine := func(line int, indentDepth int) {
+		if lastLine >= 0 {
+			buf.WriteString("\n")
+			if line > lastLine+1 {
+				buf.WriteString("\n")
+			}
+		}
+		buf.WriteString(strings.Repeat(formatIndent, indentDepth))
+	}
+
+	for _, tok := range tokens {
+		for _, c := range tok.comments {
+			if c.line == lastLine {
+				// comment at the end of a line
+				buf.WriteString(" ")
+			} else {
+				newLine(c.line, depth)
+			}
+			buf.WriteString(indentComment(c.text, depth))
+			lastLine = c.endLine
+			afterComment = true
+		}
+		if tok.typ == tokenEOF {
+			break
+		}
+
+		isCloser := tok.is("}") || tok.is("]") || tok.is(")")
+		switch {
+		case tok.line != lastLine:
+			indentDepth := depth
+			if isCloser && indentDepth > 0 {
+				indentDepth--
+			}
+			newLine(tok.line, indentDepth)
+		case afterComment || spaceBetween(prev, tok):
+			buf.WriteString(" ")
+		}
+		buf.WriteString(tok.text)
+
+		switch {
+		case tok.is("{") || tok.is("[") || tok.is("("):
+			depth++
+		case isCloser && depth > 0:
+			depth--
+		}
+		lastLine = tok.endLine
+		prev = tok
+		afterComment = false
+	}
+
+	if lastLine >= 0 {
+		buf.WriteString("\n")
+	}
+	return buf.Bytes()
+}
+
+// spaceBetween returns true when a space must be written between two tokens on the same line
+func spaceBetween(prev *token, tok *token) bool {
+	switch {
+	case prev == nil:
+		return false
+	case tok.is(",") || tok.is(";") || tok.is(":") || tok.is(")") || tok.is("]") || tok.is(">"):
+		return false
+	case prev.is("(") || prev.is("[") || prev.is("<"):
+		return false
+	case tok.is("<"):
+		return false
+	case tok.is("("):
+		// function arguments directly follow the function name, throws is followed by a space
+		return prev.is("throws")
+	}
+	return true
+}
+
+// indentComment re-indents the continuation lines of a block comment that start with an asterisk
+func indentComment(text string, depth int) string {
+	lines := strings.Split(text, "\n")
+	for i := 1; i < len(lines); i++ {
+		trimmed := strings.TrimLeft(lines[i], " \t")
+		if strings.HasPrefix(trimmed, "*") {
+			lines[i] = strings.Repeat(formatIndent, depth) + " " + trimmed
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,153 @@
+package tidm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// formatTestSources are formatted by the tests below, together with the documents in ../testfiles/huge
+var formatTestSources = map[string]string{
+	"layout": `
+const i32 x=1;typedef i64 Id
+enum Color{RED,GREEN=5,
+      BLUE}
+
+
+
+struct Point
+{
+1:i32 x,
+    2: optional   i32 y = 3
+}
+service Points extends Base{Point get(1:Id id)throws(1:NotFound nf),
+  oneway void ping()
+}
+exception NotFound{1:string why}
+service Base {}
+`,
+	"comments": `# hash comment at the start
+// line comment
+const i32 x = 1 // trailing line comment
+const i32 y = 2 # trailing hash comment
+
+/**
+   * Doc comment for Point,
+     * with odd indentation.
+ */
+struct Point {
+  /* block comment before a field */ 1: i32 x, /* trailing block comment */
+      2: i32 y // trailing comment in a struct
+  // comment before the closing brace
+}
+/* block comment
+   over several lines */ const string s = "/* not a comment */"
+// comment at the end of the document`,
+	"containers": `const map<string,list<i32>> m = {"a":[1,2,3],"b":[]}
+const list<map<i32,string>> l = [{1:"one"},{2:"two"}]
+struct S { 1: set<i32> ids = [1, 2], 2: map<string, S> children }
+`,
+}
+
+// formatTestDocuments returns the sources to format, by name
+func formatTestDocuments(t *testing.T) map[string]string {
+	documents := make(map[string]string)
+	for name, source := range formatTestSources {
+		documents[name] = source
+	}
+	filenames, err := filepath.Glob(filepath.Join("..", "testfiles", "huge", "*.thrift"))
+	if err != nil {
+		t.Fatalf("error searching testfiles: %s", err)
+	}
+	for _, filename := range filenames {
+		source, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatalf("error reading %s: %s", filename, err)
+		}
+		documents[filename] = string(source)
+	}
+	return documents
+}
+
+// formatSource formats the given source as document test.thrift
+func formatSource(t *testing.T, source string) string {
+	tidm := NewTIDM()
+	if err := tidm.AddDocument(DocumentName("test.thrift"), strings.NewReader(source)); err != nil {
+		t.Fatalf("error adding document: %s", err)
+	}
+	formatted, diags := tidm.Format(DocumentName("test.thrift"))
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %v", diags)
+	}
+	return string(formatted)
+}
+
+// sourceComments returns the comments in the source, with whitespace normalized as the formatter re-indents block comments
+func sourceComments(t *testing.T, source string) []string {
+	doc, perrs := tokenizeSource(t, source)
+	if len(perrs) > 0 {
+		t.Fatalf("unexpected errors: %v", perrs)
+	}
+	var comments []string
+	for _, tok := range doc.tokens {
+		for _, c := range tok.comments {
+			comments = append(comments, strings.Join(strings.Fields(c.text), " "))
+		}
+	}
+	return comments
+}
+
+func TestFormatIdempotent(t *testing.T) {
+	for name, source := range formatTestDocuments(t) {
+		formatted := formatSource(t, source)
+		if again := formatSource(t, formatted); again != formatted {
+			t.Errorf("%s: formatting a formatted document changed it:\n%s\nformatted again:\n%s", name, formatted, again)
+		}
+	}
+}
+
+func TestFormatKeepsComments(t *testing.T) {
+	for name, source := range formatTestDocuments(t) {
+		expected := sourceComments(t, source)
+		got := sourceComments(t, formatSource(t, source))
+		if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+			t.Errorf("%s: expected comments\n%s\ngot\n%s", name, strings.Join(expected, "\n"), strings.Join(got, "\n"))
+		}
+	}
+}
+
+func TestFormatKeepsDefinitions(t *testing.T) {
+	for name, source := range formatTestDocuments(t) {
+		original, diags := parseTestDocument(source)
+		if diags.HasErrors() {
+			t.Fatalf("%s: unexpected errors: %v", name, diags)
+		}
+		formatted, diags := parseTestDocument(formatSource(t, source))
+		if diags.HasErrors() {
+			t.Fatalf("%s: unexpected errors in formatted document: %v", name, diags)
+		}
+
+		expected := describeDocument(original.Documents["test.thrift"])
+		if got := describeDocument(formatted.Documents["test.thrift"]); got != expected {
+			t.Errorf("%s: expected definitions\n%s\ngot\n%s", name, expected, got)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	source := "struct Point{1:i32 x,\n      2: list<i32>   y}\n\n\n\nconst i32 z=1 // z\n"
+	expected := "struct Point { 1: i32 x,\n  2: list<i32> y }\n\nconst i32 z = 1 // z\n"
+	if got := formatSource(t, source); got != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, got)
+	}
+}
+
+func TestFormatInvalidDocument(t *testing.T) {
+	tidm := NewTIDM()
+	tidm.AddDocument(DocumentName("test.thrift"), strings.NewReader("struct Point { 1: i32 }"))
+	formatted, diags := tidm.Format(DocumentName("test.thrift"))
+	if !diags.HasErrors() || formatted != nil {
+		t.Errorf("expected errors and no output for an invalid document, got %v and %q", diags, formatted)
+	}
+}
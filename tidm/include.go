@@ -0,0 +1,168 @@
+package tidm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// findInclude searches the file for an include statement, the absolute filename is returned.
+// The directory of the including document is searched first, then the include paths.
+func (t *TIDM) findInclude(doc *Document, filename string) (string, bool) {
+	var candidates []string
+	if filepath.IsAbs(filename) {
+		candidates = append(candidates, filename)
+	} else {
+		if len(doc.path) > 0 {
+			candidates = append(candidates, filepath.Join(filepath.Dir(doc.path), filename))
+		}
+		for _, includePath := range t.includePaths {
+			candidates = append(candidates, filepath.Join(includePath, filename))
+		}
+	}
+
+	for _, candidate := range candidates {
+		if fi, err := os.Stat(candidate); err == nil && !fi.IsDir() {
+			if absCandidate, err := filepath.Abs(candidate); err == nil {
+				return absCandidate, true
+			}
+		}
+	}
+	return "", false
+}
+
+// includePrefix returns the prefix used to reference definitions from an included document
+// For `include "shared/base.thrift"` the prefix is `base`.
+func includePrefix(filename string) string {
+	base := filepath.Base(filename)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// loadInclude returns the document for an include statement.
+// Each file is loaded only once, a document that was loaded before is returned with isNew set to false.
+func (t *TIDM) loadInclude(doc *Document, include *includeStatement) (includedDoc *Document, isNew bool, perr *ParseError) {
+	name := DocumentName(filepath.Base(include.filename))
+	filename, found := t.findInclude(doc, include.filename)
+
+	// file was loaded before
+	if found {
+		if existing, exists := t.documentPaths[filename]; exists {
+			return existing, false, nil
+		}
+	}
+
+	// document with the same name was added from a reader (not a file)
+	// A document that was read from another file is never used, even when the included file could not be found.
+	if existing, exists := t.Documents[name]; exists && (len(existing.path) == 0 || found) {
+		if len(existing.path) == 0 {
+			return existing, false, nil
+		}
+		return nil, false, &ParseError{
+			Type:    ParseErrorTypeInvalidInclude,
+			Message: fmt.Sprintf("Cannot include '%s', another document with name '%s' was loaded from '%s'.", filename, name, existing.path),
+			DocLine: include.docLine,
+		}
+	}
+
+	if !found {
+		return nil, false, &ParseError{
+			Type:    ParseErrorTypeInvalidInclude,
+			Message: fmt.Sprintf("Could not find included document '%s'.", include.filename),
+			DocLine: include.docLine,
+		}
+	}
+
+	includedDoc, err := t.newDocumentFromFile(name, filename)
+	if err != nil {
+		return nil, false, &ParseError{
+			Type:    ParseErrorTypeInvalidInclude,
+			Message: fmt.Sprintf("Could not load included document '%s': %s", include.filename, err),
+			DocLine: include.docLine,
+		}
+	}
+	return includedDoc, true, nil
+}
+
+// loadIncludes loads the documents included by given document.
+// Documents that are new to the TIDM are returned, these must be parsed.
+// Include statements that cannot be loaded are added to the returned errors.
+func (t *TIDM) loadIncludes(doc *Document) (newDocs []*Document, perrs ParseErrors) {
+	for _, include := range doc.includeStatements {
+		prefix := includePrefix(include.filename)
+		if existing, exists := doc.Includes[prefix]; exists {
+			perrs = append(perrs, &ParseError{
+				Type:    ParseErrorTypeInvalidInclude,
+				Message: fmt.Sprintf("Include prefix '%s' is used for document '%s' already.", prefix, existing),
+				DocLine: include.docLine,
+			})
+			continue
+		}
+
+		includedDoc, isNew, perr := t.loadInclude(doc, include)
+		if perr != nil {
+			perrs = append(perrs, perr)
+			continue
+		}
+		if isNew {
+			newDocs = append(newDocs, includedDoc)
+		}
+
+		include.documentName = includedDoc.Name
+		doc.Includes[prefix] = includedDoc.Name
+	}
+	return newDocs, perrs
+}
+
+// checkIncludeCycles returns a ParseError for each include cycle, a document that includes itself directly or through other documents.
+func (t *TIDM) checkIncludeCycles() (perrs ParseErrors) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[DocumentName]int)
+	var chain []*includeStatement // include statements leading to the document that is being visited
+
+	var visit func(doc *Document)
+	visit = func(doc *Document) {
+		state[doc.Name] = visiting
+		for _, include := range doc.includeStatements {
+			chain = append(chain, include)
+			switch state[include.documentName] {
+			case visiting:
+				// cycle found, it starts at the first include statement within the included document
+				start := 0
+				for chain[start].docLine.DocumentName != include.documentName {
+					start++
+				}
+				cycle := chain[start:]
+
+				steps := make([]string, 0, len(cycle))
+				related := make([]*DocLine, 0, len(cycle))
+				for _, step := range cycle {
+					steps = append(steps, fmt.Sprintf("%s includes %s", step.docLine, step.documentName))
+					related = append(related, step.docLine)
+				}
+				perrs = append(perrs, &ParseError{
+					Type:    ParseErrorTypeIncludeCycle,
+					Message: fmt.Sprintf("Include cycle detected: %s.", strings.Join(steps, ", ")),
+					DocLine: cycle[0].docLine,
+					Related: related,
+				})
+
+			case unvisited:
+				visit(t.Documents[include.documentName])
+			}
+			chain = chain[:len(chain)-1]
+		}
+		state[doc.Name] = visited
+	}
+
+	for _, doc := range t.sortedDocuments() {
+		if state[doc.Name] == unvisited {
+			visit(doc)
+		}
+	}
+	return perrs
+}
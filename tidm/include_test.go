@@ -0,0 +1,125 @@
+package tidm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckIncludeCycles(t *testing.T) {
+	tests := []struct {
+		name      string
+		documents map[string]string
+		cycles    int
+	}{
+		{"no cycle", map[string]string{
+			"a.thrift": `include "b.thrift"`,
+			"b.thrift": `include "c.thrift"`,
+			"c.thrift": ``,
+		}, 0},
+		{"self", map[string]string{
+			"a.thrift": `include "a.thrift"`,
+		}, 1},
+		{"two documents", map[string]string{
+			"a.thrift": `include "b.thrift"`,
+			"b.thrift": `include "a.thrift"`,
+		}, 1},
+		{"several cycles", map[string]string{
+			"a.thrift": `include "b.thrift"`,
+			"b.thrift": `include "a.thrift"`,
+			"c.thrift": `include "c.thrift"`,
+			"d.thrift": "include \"e.thrift\"\ninclude \"b.thrift\"",
+			"e.thrift": `include "d.thrift"`,
+		}, 3},
+	}
+
+	for _, test := range tests {
+		tidm := NewTIDM()
+		for name, source := range test.documents {
+			// a document without definitions is an error
+			tidm.AddDocument(DocumentName(name), strings.NewReader(source+"\nstruct S {}"))
+		}
+		diags := tidm.Parse()
+		if len(diags) != test.cycles {
+			t.Errorf("%s: expected %d errors, got %v", test.name, test.cycles, diags)
+			continue
+		}
+		for _, d := range diags {
+			if d.Code != ParseErrorTypeIncludeCycle.Code() {
+				t.Errorf("%s: expected include cycle, got %s", test.name, d)
+			}
+		}
+	}
+}
+
+// writeTestFiles writes the files to a temporary folder, the folder is returned
+func writeTestFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, source := range files {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatalf("error creating folder: %s", err)
+		}
+		if err := os.WriteFile(filename, []byte(source), 0644); err != nil {
+			t.Fatalf("error writing file: %s", err)
+		}
+	}
+	return dir
+}
+
+func TestMissingIncludeWithSameNamedFile(t *testing.T) {
+	// the included file does not exist, common.thrift in another folder must not be used instead
+	dir := writeTestFiles(t, map[string]string{
+		"common.thrift":   "struct Wrong {}",
+		"sub/main.thrift": "include \"vendor/common.thrift\"\nstruct Main { 1: common.Wrong w }",
+	})
+
+	tidm := NewTIDM()
+	tidm.AddDocumentFile(filepath.Join(dir, "common.thrift"))
+	tidm.AddDocumentFile(filepath.Join(dir, "sub", "main.thrift"))
+	diags := tidm.Parse()
+	if len(diags) != 1 || diags[0].Code != ParseErrorTypeInvalidInclude.Code() || !strings.Contains(diags[0].Message, "Could not find included document 'vendor/common.thrift'") {
+		t.Fatalf("expected an error for the missing include, got %v", diags)
+	}
+}
+
+func TestDuplicateIncludePrefix(t *testing.T) {
+	// both includes would be referenced with prefix 'common'
+	tidm := NewTIDM()
+	tidm.AddDocument(DocumentName("common.thrift"), strings.NewReader("struct C {}"))
+	tidm.AddDocument(DocumentName("main.thrift"), strings.NewReader("include \"common.thrift\"\ninclude \"other/common.thrift\"\nstruct Main { 1: common.C c }"))
+	diags := tidm.Parse()
+	if len(diags) != 1 || diags[0].Code != ParseErrorTypeInvalidInclude.Code() || !strings.Contains(diags[0].Message, "Include prefix 'common' is used for document 'common.thrift' already.") {
+		t.Fatalf("expected a duplicate include prefix error, got %v", diags)
+	}
+	if diags[0].DocLine == nil || diags[0].DocLine.Line != 1 {
+		t.Errorf("expected the error on the second include, got %v", diags[0].DocLine)
+	}
+}
+
+func TestDiamondInclude(t *testing.T) {
+	// a includes b and c, both include d, d must be loaded once
+	dir := writeTestFiles(t, map[string]string{
+		"a.thrift": "include \"b.thrift\"\ninclude \"c.thrift\"\nstruct A { 1: b.B b, 2: c.C c }",
+		"b.thrift": "include \"d.thrift\"\nstruct B { 1: d.D d }",
+		"c.thrift": "include \"d.thrift\"\nstruct C { 1: d.D d }",
+		"d.thrift": "struct D {}",
+	})
+
+	tidm := NewTIDM()
+	if err := tidm.AddDocumentFile(filepath.Join(dir, "a.thrift")); err != nil {
+		t.Fatalf("error adding document: %s", err)
+	}
+	if diags := tidm.Parse(); len(diags) > 0 {
+		t.Fatalf("unexpected errors: %v", diags)
+	}
+	if len(tidm.Documents) != 4 {
+		t.Fatalf("expected 4 documents, got %d", len(tidm.Documents))
+	}
+	b := tidm.Documents["b.thrift"].Structs["B"].Fields[0].Type.Reference
+	c := tidm.Documents["c.thrift"].Structs["C"].Fields[0].Type.Reference
+	if *b != *c || b.DocumentName != "d.thrift" {
+		t.Errorf("expected b and c to reference the same struct D, got %+v and %+v", b, c)
+	}
+}
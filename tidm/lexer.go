@@ -0,0 +1,412 @@
+package tidm
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// tokenType defines the kind of a token
+type tokenType int
+
+const (
+	tokenEOF            = tokenType(iota) // end of document
+	tokenIdentifier                       // identifier or keyword
+	tokenIntConstant                      // integer constant, including sign and hex notation
+	tokenDoubleConstant                   // double constant
+	tokenLiteral                          // string literal, including quotes
+	tokenSymbol                           // single character symbol
+)
+
+// symbols contains all characters that are lexed as a single tokenSymbol
+const symbols = "{}()<>[],;:=*"
+
+// token is a single lexical element from a document
+type token struct {
+	typ       tokenType
+	text      string // source text for this token
+	line      int    // line number (starting at 0)
+	column    int    // column number in runes (starting at 0)
+	endLine   int    // line number at the end of the token
+	endColumn int    // column number directly after the last rune of the token
+	doc       string // cleaned doc comment directly preceding this token
+
+	comments []*comment // all comments between the previous token and this token, see Format()
+}
+
+// comment is a comment from the source, comments are kept so that a document can be formatted without losing them
+type comment struct {
+	text    string // source text, including comment markers
+	line    int    // line number at the start of the comment
+	endLine int    // line number at the end of the comment
+}
+
+func (tok *token) String() string {
+	if tok.typ == tokenEOF {
+		return "end of document"
+	}
+	return fmt.Sprintf("'%s'", tok.text)
+}
+
+// is returns true when the token is an identifier or symbol with given text
+func (tok *token) is(text string) bool {
+	return (tok.typ == tokenIdentifier || tok.typ == tokenSymbol) && tok.text == text
+}
+
+// lexer splits the source of a document into tokens
+type lexer struct {
+	doc    *Document
+	source []rune
+	pos    int // position in source
+	line   int // line number for pos
+	column int // column number for pos
+
+	docComment string     // last doc comment, to be attached to the next token
+	comments   []*comment // comments since the last token, to be attached to the next token
+}
+
+// tokenize lexes the document source and stores the tokens in the document
+// Invalid input is reported and skipped, so that the parser can report errors for the rest of the document.
+func (doc *Document) tokenize() (perrs ParseErrors) {
+	l := &lexer{
+		doc:    doc,
+		source: []rune(strings.Join(doc.lines, "")),
+	}
+
+	doc.tokens = nil
+	doc.tokenIndex = 0
+	for {
+		tok, perr := l.nextToken()
+		if perr != nil {
+			perrs = append(perrs, perr)
+			if l.pos < len(l.source) {
+				l.advance()
+			}
+			continue
+		}
+		doc.tokens = append(doc.tokens, tok)
+		if tok.typ == tokenEOF {
+			return perrs
+		}
+	}
+}
+
+// peek returns the rune at given offset from the current position, or 0 when out of range
+func (l *lexer) peek(offset int) rune {
+	if l.pos+offset >= len(l.source) {
+		return 0
+	}
+	return l.source[l.pos+offset]
+}
+
+// advance moves the position one rune forward, keeping track of line and column
+func (l *lexer) advance() {
+	if l.source[l.pos] == '\n' {
+		l.line++
+		l.column = 0
+	} else {
+		l.column++
+	}
+	l.pos++
+}
+
+// docLine returns a DocLine for the rune at the current position
+func (l *lexer) docLine() *DocLine {
+	return &DocLine{
+		DocumentName: l.doc.Name,
+		Line:         l.line,
+		Column:       l.column,
+		EndLine:      l.line,
+		EndColumn:    l.column + 1,
+	}
+}
+
+// skipWhitespaceAndComments moves the position to the next rune that is not whitespace or part of a comment
+// Comments can be written as `// line comment`, `# line comment` or `/* block comment */`.
+func (l *lexer) skipWhitespaceAndComments() *ParseError {
+	for l.pos < len(l.source) {
+		r := l.peek(0)
+		switch {
+		case unicode.IsSpace(r):
+			l.advance()
+		case r == '#' || (r == '/' && l.peek(1) == '/'):
+			start := l.pos
+			line := l.line
+			for l.pos < len(l.source) && l.peek(0) != '\n' {
+				l.advance()
+			}
+			text := strings.TrimRightFunc(string(l.source[start:l.pos]), unicode.IsSpace)
+			l.comments = append(l.comments, &comment{text: text, line: line, endLine: line})
+		case r == '/' && l.peek(1) == '*':
+			startDocLine := l.docLine()
+			start := l.pos
+			l.advance()
+			l.advance()
+			for !(l.peek(0) == '*' && l.peek(1) == '/') {
+				if l.pos >= len(l.source) {
+					return &ParseError{
+						Type:    ParseErrorTypeInvalidToken,
+						Message: "Block comment was not closed.",
+						DocLine: startDocLine,
+					}
+				}
+				l.advance()
+			}
+			l.advance()
+			l.advance()
+
+			// doc comments start with `/**`, an empty block comment `/**/` is not a doc comment
+			text := string(l.source[start:l.pos])
+			if strings.HasPrefix(text, "/**") && text != "/**/" {
+				l.docComment = cleanDocComment(text)
+			}
+			l.comments = append(l.comments, &comment{text: text, line: startDocLine.Line, endLine: l.line})
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// nextToken lexes the next token from the source
+func (l *lexer) nextToken() (*token, *ParseError) {
+	perr := l.skipWhitespaceAndComments()
+	if perr != nil {
+		return nil, perr
+	}
+
+	tok := &token{
+		line:     l.line,
+		column:   l.column,
+		doc:      l.docComment,
+		comments: l.comments,
+	}
+	l.docComment = ""
+	l.comments = nil
+	start := l.pos
+
+	r := l.peek(0)
+	switch {
+	case l.pos >= len(l.source):
+		tok.typ = tokenEOF
+		tok.endLine, tok.endColumn = tok.line, tok.column
+		return tok, nil
+
+	case r == '_' || unicode.IsLetter(r):
+		tok.typ = tokenIdentifier
+		for r := l.peek(0); r == '_' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r); r = l.peek(0) {
+			l.advance()
+		}
+
+	case unicode.IsDigit(r) || ((r == '+' || r == '-' || r == '.') && unicode.IsDigit(l.peek(1))) || ((r == '+' || r == '-') && l.peek(1) == '.'):
+		perr := l.lexNumber(tok)
+		if perr != nil {
+			return nil, perr
+		}
+
+	case r == '"' || r == '\'':
+		tok.typ = tokenLiteral
+		l.advance()
+		for l.peek(0) != r {
+			if l.pos >= len(l.source) {
+				return nil, &ParseError{
+					Type:    ParseErrorTypeInvalidToken,
+					Message: "String literal was not closed.",
+					DocLine: &DocLine{DocumentName: l.doc.Name, Line: tok.line, Column: tok.column, EndLine: tok.line, EndColumn: tok.column + 1},
+				}
+			}
+			// skip escaped character, see unquoteLiteral()
+			if l.peek(0) == '\\' && l.pos+1 < len(l.source) {
+				l.advance()
+			}
+			l.advance()
+		}
+		l.advance()
+
+	case strings.ContainsRune(symbols, r):
+		tok.typ = tokenSymbol
+		l.advance()
+
+	default:
+		return nil, &ParseError{
+			Type:    ParseErrorTypeInvalidToken,
+			Message: fmt.Sprintf("Unexpected character '%c'.", r),
+			DocLine: l.docLine(),
+		}
+	}
+
+	tok.text = string(l.source[start:l.pos])
+	tok.endLine, tok.endColumn = l.line, l.column
+	return tok, nil
+}
+
+// lexNumber lexes an integer or double constant
+func (l *lexer) lexNumber(tok *token) *ParseError {
+	tok.typ = tokenIntConstant
+
+	// sign
+	if r := l.peek(0); r == '+' || r == '-' {
+		l.advance()
+	}
+
+	// hexadecimal integer
+	if l.peek(0) == '0' && (l.peek(1) == 'x' || l.peek(1) == 'X') {
+		l.advance()
+		l.advance()
+		if !isHexDigit(l.peek(0)) {
+			return &ParseError{
+				Type:    ParseErrorTypeInvalidToken,
+				Message: "Invalid hexadecimal constant.",
+				DocLine: l.docLine(),
+			}
+		}
+		for isHexDigit(l.peek(0)) {
+			l.advance()
+		}
+		return nil
+	}
+
+	// integer part
+	for unicode.IsDigit(l.peek(0)) {
+		l.advance()
+	}
+
+	// fraction
+	if l.peek(0) == '.' {
+		tok.typ = tokenDoubleConstant
+		l.advance()
+		for unicode.IsDigit(l.peek(0)) {
+			l.advance()
+		}
+	}
+
+	// exponent
+	if r := l.peek(0); r == 'e' || r == 'E' {
+		tok.typ = tokenDoubleConstant
+		l.advance()
+		if r := l.peek(0); r == '+' || r == '-' {
+			l.advance()
+		}
+		if !unicode.IsDigit(l.peek(0)) {
+			return &ParseError{
+				Type:    ParseErrorTypeInvalidToken,
+				Message: "Invalid exponent in double constant.",
+				DocLine: l.docLine(),
+			}
+		}
+		for unicode.IsDigit(l.peek(0)) {
+			l.advance()
+		}
+	}
+
+	return nil
+}
+
+// cleanDocComment removes the comment markers, leading asterisks and common indentation from a doc comment
+func cleanDocComment(text string) string {
+	text = strings.TrimSuffix(strings.TrimPrefix(text, "/**"), "*/")
+	lines := strings.Split(text, "\n")
+
+	// text on the opening line is not indented
+	lines[0] = strings.TrimLeft(lines[0], " \t")
+	firstLineIndented := len(lines[0]) == 0
+
+	// remove leading asterisk (and a single space after it) from each line
+	for i, line := range lines {
+		trimmed := strings.TrimLeft(line, " \t")
+		if strings.HasPrefix(trimmed, "*") {
+			lines[i] = strings.TrimPrefix(trimmed[1:], " ")
+		}
+		lines[i] = strings.TrimRightFunc(lines[i], unicode.IsSpace)
+	}
+
+	// remove leading and trailing empty lines
+	for len(lines) > 0 && len(lines[0]) == 0 {
+		lines = lines[1:]
+		firstLineIndented = true
+	}
+	for len(lines) > 0 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+
+	// remove indentation that is common to all non-empty lines
+	indent := -1
+	for i, line := range lines {
+		if len(line) == 0 || (i == 0 && !firstLineIndented) {
+			continue
+		}
+		lineIndent := len(line) - len(strings.TrimLeft(line, " \t"))
+		if indent == -1 || lineIndent < indent {
+			indent = lineIndent
+		}
+	}
+	for i, line := range lines {
+		if i == 0 && !firstLineIndented {
+			continue
+		}
+		if len(line) >= indent && indent > 0 {
+			lines[i] = line[indent:]
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// unquoteLiteral removes the quotes from a string literal and replaces escape sequences
+// Supported escape sequences are \\, \", \', \n, \r, \t and \uXXXX.
+func unquoteLiteral(text string) (string, error) {
+	runes := []rune(text[1 : len(text)-1])
+	var value []rune
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '\\' {
+			value = append(value, runes[i])
+			continue
+		}
+		i++
+		if i >= len(runes) {
+			return "", errors.New("Incomplete escape sequence.")
+		}
+		switch runes[i] {
+		case '\\', '"', '\'':
+			value = append(value, runes[i])
+		case 'n':
+			value = append(value, '\n')
+		case 'r':
+			value = append(value, '\r')
+		case 't':
+			value = append(value, '\t')
+		case 'u':
+			if i+4 >= len(runes) {
+				return "", errors.New("Incomplete unicode escape sequence.")
+			}
+			code, err := strconv.ParseUint(string(runes[i+1:i+5]), 16, 32)
+			if err != nil {
+				return "", fmt.Errorf("Invalid unicode escape sequence '\\u%s'.", string(runes[i+1:i+5]))
+			}
+			value = append(value, rune(code))
+			i += 4
+		default:
+			return "", fmt.Errorf("Invalid escape sequence '\\%c'.", runes[i])
+		}
+	}
+	return string(value), nil
+}
+
+// parseIntConstant parses the text of an integer constant token
+// Integer constants are decimal, unless they start with 0x or 0X after the optional sign. A leading 0 does not mean octal.
+func parseIntConstant(text string, bitSize int) (int64, error) {
+	sign, digits := "", text
+	if strings.HasPrefix(digits, "+") || strings.HasPrefix(digits, "-") {
+		sign, digits = digits[:1], digits[1:]
+	}
+	if strings.HasPrefix(digits, "0x") || strings.HasPrefix(digits, "0X") {
+		return strconv.ParseInt(sign+digits[2:], 16, bitSize)
+	}
+	return strconv.ParseInt(sign+digits, 10, bitSize)
+}
+
+func isHexDigit(r rune) bool {
+	return unicode.IsDigit(r) || (r >= 'a' && r <= 'f') || (r >= 'A' && r <= 'F')
+}
@@ -0,0 +1,197 @@
+package tidm
+
+import (
+	"strings"
+	"testing"
+)
+
+// tokenizeSource tokenizes the given source as document test.thrift
+func tokenizeSource(t *testing.T, source string) (*Document, ParseErrors) {
+	doc, err := newTIDM().newDocumentFromReader(DocumentName("test.thrift"), strings.NewReader(source))
+	if err != nil {
+		t.Fatalf("error creating document: %s", err)
+	}
+	return doc, doc.tokenize()
+}
+
+// testToken is the expected type and text of a token
+type testToken struct {
+	typ  tokenType
+	text string
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		tokens []testToken // expected tokens, without the EOF token
+	}{
+		{"empty", "", nil},
+		{"identifiers", "const i32 foo_bar shared.Type", []testToken{
+			{tokenIdentifier, "const"}, {tokenIdentifier, "i32"}, {tokenIdentifier, "foo_bar"}, {tokenIdentifier, "shared.Type"},
+		}},
+		{"symbols", "{}()<>[],;:=*", []testToken{
+			{tokenSymbol, "{"}, {tokenSymbol, "}"}, {tokenSymbol, "("}, {tokenSymbol, ")"}, {tokenSymbol, "<"}, {tokenSymbol, ">"},
+			{tokenSymbol, "["}, {tokenSymbol, "]"}, {tokenSymbol, ","}, {tokenSymbol, ";"}, {tokenSymbol, ":"}, {tokenSymbol, "="}, {tokenSymbol, "*"},
+		}},
+		{"no whitespace", "const i32 x=1;", []testToken{
+			{tokenIdentifier, "const"}, {tokenIdentifier, "i32"}, {tokenIdentifier, "x"}, {tokenSymbol, "="}, {tokenIntConstant, "1"}, {tokenSymbol, ";"},
+		}},
+		{"integers", "0 42 -7 +3", []testToken{
+			{tokenIntConstant, "0"}, {tokenIntConstant, "42"}, {tokenIntConstant, "-7"}, {tokenIntConstant, "+3"},
+		}},
+		{"hex", "0x1F 0XAB -0xff", []testToken{
+			{tokenIntConstant, "0x1F"}, {tokenIntConstant, "0XAB"}, {tokenIntConstant, "-0xff"},
+		}},
+		{"doubles", "1.5 -0.25 .5 +.5 3.", []testToken{
+			{tokenDoubleConstant, "1.5"}, {tokenDoubleConstant, "-0.25"}, {tokenDoubleConstant, ".5"}, {tokenDoubleConstant, "+.5"}, {tokenDoubleConstant, "3."},
+		}},
+		{"exponents", "1e10 2.5E-3 -1e+2", []testToken{
+			{tokenDoubleConstant, "1e10"}, {tokenDoubleConstant, "2.5E-3"}, {tokenDoubleConstant, "-1e+2"},
+		}},
+		{"literals", `"foo" 'bar' "it's" 'say "hi"'`, []testToken{
+			{tokenLiteral, `"foo"`}, {tokenLiteral, `'bar'`}, {tokenLiteral, `"it's"`}, {tokenLiteral, `'say "hi"'`},
+		}},
+		{"literals with escapes", `"a\"b" 'c\'d' "e\\" "\n\t"`, []testToken{
+			{tokenLiteral, `"a\"b"`}, {tokenLiteral, `'c\'d'`}, {tokenLiteral, `"e\\"`}, {tokenLiteral, `"\n\t"`},
+		}},
+		{"line comments", "a // comment\nb # comment\nc", []testToken{
+			{tokenIdentifier, "a"}, {tokenIdentifier, "b"}, {tokenIdentifier, "c"},
+		}},
+		{"block comments", "a /* comment */ b /* multi\nline */ c /**/ d", []testToken{
+			{tokenIdentifier, "a"}, {tokenIdentifier, "b"}, {tokenIdentifier, "c"}, {tokenIdentifier, "d"},
+		}},
+		{"comment markers in literal", `"// not # a /* comment"`, []testToken{
+			{tokenLiteral, `"// not # a /* comment"`},
+		}},
+	}
+
+	for _, test := range tests {
+		doc, perrs := tokenizeSource(t, test.source)
+		if len(perrs) > 0 {
+			t.Errorf("%s: unexpected errors: %v", test.name, perrs)
+			continue
+		}
+		tokens := doc.tokens[:len(doc.tokens)-1]
+		if doc.tokens[len(doc.tokens)-1].typ != tokenEOF {
+			t.Errorf("%s: last token is not EOF", test.name)
+		}
+		if len(tokens) != len(test.tokens) {
+			t.Errorf("%s: expected %d tokens, got %d: %v", test.name, len(test.tokens), len(tokens), tokens)
+			continue
+		}
+		for i, tok := range tokens {
+			if tok.typ != test.tokens[i].typ || tok.text != test.tokens[i].text {
+				t.Errorf("%s: token %d: expected %q (type %d), got %q (type %d)", test.name, i, test.tokens[i].text, test.tokens[i].typ, tok.text, tok.typ)
+			}
+		}
+	}
+}
+
+func TestTokenizePositions(t *testing.T) {
+	doc, perrs := tokenizeSource(t, "struct Foo {\n  1: i32 x\n}")
+	if len(perrs) > 0 {
+		t.Fatalf("unexpected errors: %v", perrs)
+	}
+
+	// line, column, endLine and endColumn for each token
+	expected := [][4]int{
+		{0, 0, 0, 6}, {0, 7, 0, 10}, {0, 11, 0, 12},
+		{1, 2, 1, 3}, {1, 3, 1, 4}, {1, 5, 1, 8}, {1, 9, 1, 10},
+		{2, 0, 2, 1},
+	}
+	for i, pos := range expected {
+		tok := doc.tokens[i]
+		if got := [4]int{tok.line, tok.column, tok.endLine, tok.endColumn}; got != pos {
+			t.Errorf("token %s: expected position %v, got %v", tok, pos, got)
+		}
+	}
+}
+
+func TestTokenizeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		message string // part of the expected error message
+		line    int
+		column  int
+	}{
+		{"unclosed block comment", "a /* comment", "Block comment was not closed.", 0, 2},
+		{"unclosed multi line block comment", "a\n/* comment\nstill comment", "Block comment was not closed.", 1, 0},
+		{"unclosed string", `const string s = "foo`, "String literal was not closed.", 0, 17},
+		{"unclosed string with escaped quote", `"foo\"`, "String literal was not closed.", 0, 0},
+		{"invalid hex", "0x", "Invalid hexadecimal constant.", 0, 2},
+		{"invalid exponent", "1e", "Invalid exponent in double constant.", 0, 2},
+		{"unexpected character", "a $ b", "Unexpected character '$'.", 0, 2},
+	}
+
+	for _, test := range tests {
+		_, perrs := tokenizeSource(t, test.source)
+		if len(perrs) == 0 {
+			t.Errorf("%s: expected an error", test.name)
+			continue
+		}
+		perr := perrs[0]
+		if !strings.Contains(perr.Message, test.message) {
+			t.Errorf("%s: expected error %q, got %q", test.name, test.message, perr.Message)
+		}
+		if perr.DocLine == nil || perr.DocLine.Line != test.line || perr.DocLine.Column != test.column {
+			t.Errorf("%s: expected error at %d:%d, got %v", test.name, test.line+1, test.column+1, perr.DocLine)
+		}
+	}
+}
+
+func TestTokenizeRecovers(t *testing.T) {
+	// the invalid character is reported and skipped, the other tokens are kept
+	doc, perrs := tokenizeSource(t, "a $ b % c")
+	if len(perrs) != 2 {
+		t.Fatalf("expected 2 errors, got %v", perrs)
+	}
+	if len(doc.tokens) != 4 {
+		t.Fatalf("expected 3 tokens and EOF, got %v", doc.tokens)
+	}
+}
+
+func TestDocComment(t *testing.T) {
+	doc, perrs := tokenizeSource(t, "/** not attached */\n/**\n * Foo is a struct.\n *\n *   indented\n */\nstruct Foo {}")
+	if len(perrs) > 0 {
+		t.Fatalf("unexpected errors: %v", perrs)
+	}
+	expected := "Foo is a struct.\n\n  indented"
+	if doc.tokens[0].doc != expected {
+		t.Errorf("expected doc comment %q, got %q", expected, doc.tokens[0].doc)
+	}
+}
+
+func TestUnquoteLiteral(t *testing.T) {
+	tests := []struct {
+		literal string
+		value   string
+		err     bool
+	}{
+		{`"foo"`, "foo", false},
+		{`'foo'`, "foo", false},
+		{`""`, "", false},
+		{`"a\"b"`, `a"b`, false},
+		{`'a\'b'`, "a'b", false},
+		{`"a\\b"`, `a\b`, false},
+		{`"\n\r\t"`, "\n\r\t", false},
+		{`"\u0041\u00e9"`, "Aé", false},
+		{`"é"`, "é", false},
+		{`"\u00"`, "", true},
+		{`"\u00zz"`, "", true},
+		{`"\x"`, "", true},
+	}
+
+	for _, test := range tests {
+		value, err := unquoteLiteral(test.literal)
+		switch {
+		case test.err && err == nil:
+			t.Errorf("%s: expected an error", test.literal)
+		case !test.err && err != nil:
+			t.Errorf("%s: unexpected error: %s", test.literal, err)
+		case value != test.value:
+			t.Errorf("%s: expected %q, got %q", test.literal, test.value, value)
+		}
+	}
+}
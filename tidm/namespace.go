@@ -19,6 +19,7 @@ type Namespace struct {
 	TypedefReferences   map[IdentifierName]*TypedefReference
 	EnumReferences      map[IdentifierName]*EnumReference
 	StructReferences    map[IdentifierName]*StructReference
+	UnionReferences     map[IdentifierName]*UnionReference
 	ExceptionReferences map[IdentifierName]*ExceptionReference
 	ServiceReferences   map[IdentifierName]*ServiceReference
 
@@ -45,6 +46,7 @@ func (target *Target) newNamespace(name NamespaceName) (*Namespace, error) {
 		TypedefReferences:   make(map[IdentifierName]*TypedefReference),
 		EnumReferences:      make(map[IdentifierName]*EnumReference),
 		StructReferences:    make(map[IdentifierName]*StructReference),
+		UnionReferences:     make(map[IdentifierName]*UnionReference),
 		ExceptionReferences: make(map[IdentifierName]*ExceptionReference),
 		ServiceReferences:   make(map[IdentifierName]*ServiceReference),
 	}
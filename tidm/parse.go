@@ -3,7 +3,7 @@ package tidm
 import (
 	"fmt"
 	"regexp"
-	"strings"
+	"strconv"
 )
 
 type ParseErrorType int
@@ -17,7 +17,22 @@ const (
 	ParseErrorTypeInvalidIdentifier
 	ParseErrorTypeDuplicateIdentifier
 	ParseErrorTypeUnexpectedError
-	ParseErrorNotSupported
+	ParseErrorNotSupported // no longer used, its code is kept reserved
+	ParseErrorTypeInvalidEnumDefinition
+	ParseErrorTypeUnexpectedEndOfDocument
+	ParseErrorTypeInvalidStructDefinition
+	ParseErrorTypeInvalidFieldDefinition
+	ParseErrorTypeInvalidExceptionDefinition
+	ParseErrorTypeInvalidServiceDefinition
+	ParseErrorTypeInvalidFunctionDefinition
+	ParseErrorTypeInvalidUnionDefinition
+	ParseErrorTypeInvalidToken
+	ParseErrorTypeUnexpectedToken
+	ParseErrorTypeInvalidInclude
+	ParseErrorTypeUnknownIdentifier
+	ParseErrorTypeIncludeCycle
+	ParseErrorTypeTypedefCycle
+	ParseErrorTypeInvalidConstValue
 )
 
 // ParseError contains information about a parse error
@@ -26,6 +41,7 @@ type ParseError struct {
 	Type    ParseErrorType // Type of error
 	Message string         // Error message
 	DocLine *DocLine       // DocLine where the problem has ocurred
+	Related []*DocLine     // Other DocLines involved in the problem (e.g. each include statement for an include cycle)
 }
 
 // Error method to implement the go-builtin error interface
@@ -33,242 +49,943 @@ func (pe *ParseError) Error() string {
 	return pe.Message
 }
 
+// ParseErrors is a list of ParseErrors
+// ParseErrors implements the go-builtin error interface
+type ParseErrors []*ParseError
+
+// Error method to implement the go-builtin error interface
+func (perrs ParseErrors) Error() string {
+	switch len(perrs) {
+	case 0:
+		return "no errors"
+	case 1:
+		return perrs[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", perrs[0].Error(), len(perrs)-1)
+}
+
 var (
-	regexpMatchIdentifier = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_\-\.]*$`)
+	regexpMatchIdentifier = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
 
-	// TODO(GeertJohan): Add escape functionality to double-quoted string
-	// TODO(GeertJohan): remove support for string literal with single quote.
-	regexpMatchStringLiteral = regexp.MustCompile(`^(?:"[^"]*") | (?:'[^']*')$`)
+// headerKeywords and definitionKeywords contain the keywords that start a header or definition
+var (
+	headerKeywords     = []string{"include", "cpp_include", "namespace"}
+	definitionKeywords = []string{"typedef", "const", "enum", "struct", "union", "exception", "service"}
 )
 
-// nextMeaningfulLine gives the next line that is not empty nor a comment
-// when an empty line is returned, parsing should be stopped
-func (doc *Document) nextMeaningfulLine() string {
-	for {
-		// check if the complete doc has been parsed
-		if len(doc.lines)-1 == doc.lastParsedLineNumber {
-			return "" // parser should stop on empty line
+// isKeyword returns true when the given token starts a header or definition
+func isKeyword(tok *token) bool {
+	if tok.typ != tokenIdentifier {
+		return false
+	}
+	for _, keyword := range headerKeywords {
+		if tok.text == keyword {
+			return true
+		}
+	}
+	for _, keyword := range definitionKeywords {
+		if tok.text == keyword {
+			return true
 		}
+	}
+	return false
+}
 
-		// fetch next line
-		doc.lastParsedLineNumber++
-		line := doc.lines[doc.lastParsedLineNumber]
+// peekToken returns the next token without consuming it
+func (doc *Document) peekToken() *token {
+	return doc.tokens[doc.tokenIndex]
+}
 
-		// fast path for empty line
-		if len(line) == 0 {
-			continue
+// nextToken consumes and returns the next token
+// After the end of the document has been reached, the EOF token is returned on each call.
+func (doc *Document) nextToken() *token {
+	tok := doc.tokens[doc.tokenIndex]
+	if tok.typ != tokenEOF {
+		doc.tokenIndex++
+	}
+	return tok
+}
+
+// tokenDocLine creates a DocLine for given token
+func (doc *Document) tokenDocLine(tok *token) *DocLine {
+	return &DocLine{
+		DocumentName: doc.Name,
+		Line:         tok.line,
+		Column:       tok.column,
+		EndLine:      tok.endLine,
+		EndColumn:    tok.endColumn,
+	}
+}
+
+// rangeDocLine creates a DocLine from the start of given token to the end of the last consumed token
+func (doc *Document) rangeDocLine(start *token) *DocLine {
+	dl := doc.tokenDocLine(start)
+	if doc.tokenIndex > 0 {
+		end := doc.tokens[doc.tokenIndex-1]
+		dl.EndLine, dl.EndColumn = end.endLine, end.endColumn
+	}
+	return dl
+}
+
+// unexpectedToken creates a ParseError for an unexpected token
+func (doc *Document) unexpectedToken(tok *token, expecting string) *ParseError {
+	perr := &ParseError{
+		Type:    ParseErrorTypeUnexpectedToken,
+		Message: fmt.Sprintf("Unexpected %s, expecting %s.", tok, expecting),
+		DocLine: doc.tokenDocLine(tok),
+	}
+	if tok.typ == tokenEOF {
+		perr.Type = ParseErrorTypeUnexpectedEndOfDocument
+	}
+	return perr
+}
+
+// expectSymbol consumes the next token, which must be the given symbol
+func (doc *Document) expectSymbol(symbol string) (*token, *ParseError) {
+	tok := doc.nextToken()
+	if tok.typ != tokenSymbol || tok.text != symbol {
+		return nil, doc.unexpectedToken(tok, fmt.Sprintf("'%s'", symbol))
+	}
+	return tok, nil
+}
+
+// expectIdentifier consumes the next token, which must be an identifier
+func (doc *Document) expectIdentifier() (*token, *ParseError) {
+	tok := doc.nextToken()
+	if tok.typ != tokenIdentifier {
+		return nil, doc.unexpectedToken(tok, "identifier")
+	}
+	return tok, nil
+}
+
+// skipListSeparator consumes the next token when it is a ListSeparator (',' or ';')
+func (doc *Document) skipListSeparator() {
+	if tok := doc.peekToken(); tok.is(",") || tok.is(";") {
+		doc.nextToken()
+	}
+}
+
+// checkIdentifier returns a ParseError when the given token is not a valid identifier
+// The lexer accepts dots within identifiers for qualified names (`shared.Type`), these are not valid for a declaration.
+func (doc *Document) checkIdentifier(tok *token) *ParseError {
+	if !regexpMatchIdentifier.MatchString(tok.text) {
+		return &ParseError{
+			Type:    ParseErrorTypeInvalidIdentifier,
+			Message: fmt.Sprintf("Invalid identifier '%s'.", tok.text),
+			DocLine: doc.tokenDocLine(tok),
 		}
+	}
+	return nil
+}
+
+// declareIdentifier checks if the given token is a valid and unique identifier within the document.
+// The new identifier is registered with the document and returned.
+func (doc *Document) declareIdentifier(tok *token) (*Identifier, *ParseError) {
+	docLine := doc.tokenDocLine(tok)
+
+	// check for identifier to be valid
+	if perr := doc.checkIdentifier(tok); perr != nil {
+		return nil, perr
+	}
 
-		// remove comments from line
-		pos := strings.Index(line, "//")
-		if pos > -1 {
-			line = line[:pos]
+	// check if identifier is unique
+	if i, exists := doc.identifiers[IdentifierName(tok.text)]; exists {
+		return nil, &ParseError{
+			Type:    ParseErrorTypeDuplicateIdentifier,
+			Message: fmt.Sprintf("The given identifier has been declared before in this document. Previous declaration at %s", i.DocLine),
+			DocLine: docLine,
 		}
+	}
 
-		// trim space and list seperators from line
-		line = strings.TrimSpace(line)
+	// save identifier
+	identifier := &Identifier{
+		Name:    IdentifierName(tok.text),
+		DocLine: docLine,
+	}
+	doc.identifiers[identifier.Name] = identifier
+	return identifier, nil
+}
 
-		// try next line if this one is empty after removing
-		if len(line) == 0 {
-			continue
+// expectName consumes the next token, which must be a valid identifier for an enum value, field or function.
+// The name is not registered with the document, it only has to be unique within its definition.
+func (doc *Document) expectName() (*Identifier, *ParseError) {
+	tok, perr := doc.expectIdentifier()
+	if perr != nil {
+		return nil, perr
+	}
+	if perr = doc.checkIdentifier(tok); perr != nil {
+		return nil, perr
+	}
+	return &Identifier{
+		Name:    IdentifierName(tok.text),
+		DocLine: doc.tokenDocLine(tok),
+	}, nil
+}
+
+// expectDeclaration consumes the next token, which must be a new identifier for a definition
+func (doc *Document) expectDeclaration() (*Identifier, *ParseError) {
+	tok, perr := doc.expectIdentifier()
+	if perr != nil {
+		return nil, perr
+	}
+	return doc.declareIdentifier(tok)
+}
+
+// parseFieldType parses a field type
+// FieldType     = identifier | BaseType | ContainerType .
+// ContainerType = "map" "<" FieldType "," FieldType ">" | "set" "<" FieldType ">" | "list" "<" FieldType ">" .
+func (doc *Document) parseFieldType() (*FieldType, *ParseError) {
+	tok, perr := doc.expectIdentifier()
+	if perr != nil {
+		return nil, perr
+	}
+
+	switch {
+	case tok.is("list"), tok.is("set"):
+		ft := &FieldType{
+			Kind:    FieldTypeKind(tok.text),
+			DocLine: doc.tokenDocLine(tok),
+		}
+		if _, perr = doc.expectSymbol("<"); perr != nil {
+			return nil, perr
+		}
+		if ft.ElemType, perr = doc.parseFieldType(); perr != nil {
+			return nil, perr
+		}
+		if _, perr = doc.expectSymbol(">"); perr != nil {
+			return nil, perr
+		}
+		ft.DocLine = doc.rangeDocLine(tok)
+		return ft, nil
+
+	case tok.is("map"):
+		ft := &FieldType{
+			Kind:    FieldTypeKindMap,
+			DocLine: doc.tokenDocLine(tok),
+		}
+		if _, perr = doc.expectSymbol("<"); perr != nil {
+			return nil, perr
+		}
+		if ft.KeyType, perr = doc.parseFieldType(); perr != nil {
+			return nil, perr
+		}
+		if _, perr = doc.expectSymbol(","); perr != nil {
+			return nil, perr
+		}
+		if ft.ValueType, perr = doc.parseFieldType(); perr != nil {
+			return nil, perr
+		}
+		if _, perr = doc.expectSymbol(">"); perr != nil {
+			return nil, perr
+		}
+		ft.DocLine = doc.rangeDocLine(tok)
+		return ft, nil
+
+	case tok.is("void"):
+		return nil, &ParseError{
+			Type:    ParseErrorTypeUnexpectedKeyword,
+			Message: "Error: void is only valid as function return type.",
+			DocLine: doc.tokenDocLine(tok),
 		}
 
-		// found valid line, return it.
-		return line
+	case isBaseType(tok.text):
+		return &FieldType{
+			Kind:    FieldTypeKindBase,
+			Name:    tok.text,
+			DocLine: doc.tokenDocLine(tok),
+		}, nil
+
+	default:
+		return &FieldType{
+			Kind:    FieldTypeKindNamed,
+			Name:    tok.text,
+			DocLine: doc.tokenDocLine(tok),
+		}, nil
 	}
 }
 
-// parseDocumentHeaders parses document headers
-func (doc *Document) parseDocumentHeaders() *ParseError {
-	// loop through lines
-	for {
+// parseConstValue parses a constant value
+// The value is checked against its type after all documents have been parsed, see TIDM.checkConstValues().
+// ConstValue = IntConstant | DoubleConstant | Literal | identifier | ConstList | ConstMap .
+// ConstList  = "[" { ConstValue [ListSeparator] } "]" .
+// ConstMap   = "{" { ConstValue ":" ConstValue [ListSeparator] } "}" .
+func (doc *Document) parseConstValue() (*ConstValue, *ParseError) {
+	tok := doc.nextToken()
+	cv := &ConstValue{
+		DocLine: doc.tokenDocLine(tok),
+	}
+
+	switch {
+	case tok.typ == tokenIntConstant:
+		v, err := parseIntConstant(tok.text, 64)
+		if err != nil {
+			return nil, &ParseError{
+				Type:    ParseErrorTypeInvalidConstValue,
+				Message: fmt.Sprintf("Invalid integer constant '%s'. Value must fit in 64 bits.", tok.text),
+				DocLine: cv.DocLine,
+			}
+		}
+		cv.Kind = ConstValueKindInt
+		cv.Int = v
+
+	case tok.typ == tokenDoubleConstant:
+		v, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, &ParseError{
+				Type:    ParseErrorTypeInvalidConstValue,
+				Message: fmt.Sprintf("Invalid double constant '%s'.", tok.text),
+				DocLine: cv.DocLine,
+			}
+		}
+		cv.Kind = ConstValueKindDouble
+		cv.Double = v
+
+	case tok.typ == tokenLiteral:
+		v, err := unquoteLiteral(tok.text)
+		if err != nil {
+			return nil, &ParseError{
+				Type:    ParseErrorTypeInvalidConstValue,
+				Message: fmt.Sprintf("Invalid string literal. %s", err),
+				DocLine: cv.DocLine,
+			}
+		}
+		cv.Kind = ConstValueKindString
+		cv.String = v
+
+	case tok.typ == tokenIdentifier && !isKeyword(tok):
+		// name of a const or enum value, or true/false. Resolved by TIDM.checkConstValues().
+		cv.Kind = constValueKindIdentifier
+		cv.Name = tok.text
+
+	case tok.is("["):
+		cv.Kind = ConstValueKindList
+		for !doc.peekToken().is("]") {
+			if doc.peekToken().typ == tokenEOF {
+				return nil, doc.unexpectedToken(doc.peekToken(), "']'")
+			}
+			elem, perr := doc.parseConstValue()
+			if perr != nil {
+				return nil, perr
+			}
+			cv.List = append(cv.List, elem)
+			doc.skipListSeparator()
+		}
+		doc.nextToken() // closing bracket
+		cv.DocLine = doc.rangeDocLine(tok)
+
+	case tok.is("{"):
+		cv.Kind = ConstValueKindMap
+		for !doc.peekToken().is("}") {
+			if doc.peekToken().typ == tokenEOF {
+				return nil, doc.unexpectedToken(doc.peekToken(), "'}'")
+			}
+			key, perr := doc.parseConstValue()
+			if perr != nil {
+				return nil, perr
+			}
+			if _, perr = doc.expectSymbol(":"); perr != nil {
+				return nil, perr
+			}
+			value, perr := doc.parseConstValue()
+			if perr != nil {
+				return nil, perr
+			}
+			cv.Map = append(cv.Map, &ConstMapEntry{Key: key, Value: value})
+			doc.skipListSeparator()
+		}
+		doc.nextToken() // closing brace
+		cv.DocLine = doc.rangeDocLine(tok)
+
+	default:
+		return nil, doc.unexpectedToken(tok, "constant value")
+	}
+
+	return cv, nil
+}
+
+// parseTypedef parses a typedef definition, the typedef keyword has been consumed.
+// Typedef = "typedef" DefinitionType identifier .
+func (doc *Document) parseTypedef(docComment string) *ParseError {
+	definitionType, perr := doc.parseFieldType()
+	if perr != nil {
+		return perr
+	}
+	identifier, perr := doc.expectDeclaration()
+	if perr != nil {
+		return perr
+	}
+
+	// save typedef
+	t := &Typedef{
+		Identifier: identifier,
+		Doc:        docComment,
+		Type:       definitionType,
+	}
+	doc.Typedefs[t.Identifier.Name] = t
+	return nil
+}
+
+// parseConst parses a const definition, the const keyword has been consumed.
+// Const = "const" FieldType identifier "=" ConstValue .
+func (doc *Document) parseConst(docComment string) *ParseError {
+	fieldType, perr := doc.parseFieldType()
+	if perr != nil {
+		return perr
+	}
+	identifier, perr := doc.expectDeclaration()
+	if perr != nil {
+		return perr
+	}
+	if _, perr = doc.expectSymbol("="); perr != nil {
+		return perr
+	}
+	value, perr := doc.parseConstValue()
+	if perr != nil {
+		return perr
+	}
+
+	// save constant
+	c := &Const{
+		Type:       fieldType,
+		Identifier: identifier,
+		Doc:        docComment,
+		Value:      value,
+	}
+	doc.Consts[c.Identifier.Name] = c
+	return nil
+}
+
+// parseEnum parses an enum definition, the enum keyword has been consumed.
+// Enum = "enum" identifier "{" { identifier ["=" IntConstant] [ListSeparator] } "}" .
+func (doc *Document) parseEnum(docComment string) *ParseError {
+	identifier, perr := doc.expectDeclaration()
+	if perr != nil {
+		return perr
+	}
+	if _, perr = doc.expectSymbol("{"); perr != nil {
+		return perr
+	}
+
+	e := &Enums{
+		Identifier: identifier,
+		Doc:        docComment,
+	}
+	names := make(map[IdentifierName]*Identifier)
+	nextValue := 0
+	for !doc.peekToken().is("}") {
+		valueDoc := doc.peekToken().doc
+		valueIdentifier, perr := doc.expectName()
+		if perr != nil {
+			return perr
+		}
+		ev := &EnumValue{
+			Identifier: valueIdentifier,
+			Doc:        valueDoc,
+			Value:      nextValue,
+		}
+
+		// explicit value
+		if doc.peekToken().is("=") {
+			doc.nextToken()
+			valueToken := doc.nextToken()
+			if valueToken.typ != tokenIntConstant {
+				return doc.unexpectedToken(valueToken, "integer value")
+			}
+			v, err := parseIntConstant(valueToken.text, 32)
+			if err != nil {
+				return &ParseError{
+					Type:    ParseErrorTypeInvalidEnumDefinition,
+					Message: fmt.Sprintf("Invalid value for enum value '%s'. Expecting a 32 bit integer.", ev.Identifier.Name),
+					DocLine: doc.tokenDocLine(valueToken),
+				}
+			}
+			ev.Value = int(v)
+		}
+
+		// check for unique enum value name
+		if i, exists := names[ev.Identifier.Name]; exists {
+			return &ParseError{
+				Type:    ParseErrorTypeDuplicateIdentifier,
+				Message: fmt.Sprintf("The given enum value has been declared before in this enum. Previous declaration at %s", i.DocLine),
+				DocLine: ev.Identifier.DocLine,
+			}
+		}
+		names[ev.Identifier.Name] = ev.Identifier
+		e.Values = append(e.Values, ev)
+		nextValue = ev.Value + 1
+
+		doc.skipListSeparator()
+	}
+	doc.nextToken() // closing brace
+
+	// save enum
+	doc.Enums[e.Identifier.Name] = e
+	return nil
+}
+
+// parseField parses a single field
+// When the field has no explicit id, it is given the implicit id, which is then decremented.
+// Field = [FieldID ":"] [FieldReq] FieldType identifier ["=" ConstValue] [ListSeparator] .
+func (doc *Document) parseField(nextImplicitID *int) (*Field, *ParseError) {
+	start := doc.peekToken()
+	f := &Field{
+		Requiredness: FieldRequirednessDefault,
+		Doc:          start.doc,
+	}
+
+	// field id
+	if tok := doc.peekToken(); tok.typ == tokenIntConstant {
+		doc.nextToken()
+		id, err := parseIntConstant(tok.text, 16)
+		if err != nil {
+			return nil, &ParseError{
+				Type:    ParseErrorTypeInvalidFieldDefinition,
+				Message: fmt.Sprintf("Invalid field id '%s'.", tok.text),
+				DocLine: doc.tokenDocLine(tok),
+			}
+		}
+		f.ID = int(id)
+		if _, perr := doc.expectSymbol(":"); perr != nil {
+			return nil, perr
+		}
+	} else {
+		f.ID = *nextImplicitID
+		*nextImplicitID--
+	}
+
+	// requiredness
+	if tok := doc.peekToken(); tok.is("required") || tok.is("optional") {
+		doc.nextToken()
+		f.Requiredness = FieldRequiredness(tok.text)
+	}
+
+	// type and name
+	var perr *ParseError
+	f.Type, perr = doc.parseFieldType()
+	if perr != nil {
+		return nil, perr
+	}
+	f.Identifier, perr = doc.expectName()
+	if perr != nil {
+		return nil, perr
+	}
+
+	// default value
+	if doc.peekToken().is("=") {
+		doc.nextToken()
+		f.DefaultValue, perr = doc.parseConstValue()
+		if perr != nil {
+			return nil, perr
+		}
+	}
+	f.DocLine = doc.rangeDocLine(start)
+
+	doc.skipListSeparator()
+	return f, nil
+}
+
+// parseFieldList parses fields until the given closing symbol, which is consumed.
+// Fields without explicit id are given a negative id, starting at -1.
+func (doc *Document) parseFieldList(closingSymbol string) ([]*Field, *ParseError) {
+	var fields []*Field
+	ids := make(map[int]*Field)
+	names := make(map[IdentifierName]*Field)
+	nextImplicitID := -1
+
+	for !doc.peekToken().is(closingSymbol) {
+		if doc.peekToken().typ == tokenEOF {
+			return nil, doc.unexpectedToken(doc.peekToken(), fmt.Sprintf("'%s'", closingSymbol))
+		}
+
+		f, perr := doc.parseField(&nextImplicitID)
+		if perr != nil {
+			return nil, perr
+		}
+
+		// check for unique id and name
+		if existing, exists := ids[f.ID]; exists {
+			return nil, &ParseError{
+				Type:    ParseErrorTypeInvalidFieldDefinition,
+				Message: fmt.Sprintf("Field id %d has been used before. Previous declaration at %s", f.ID, existing.Identifier.DocLine),
+				DocLine: f.Identifier.DocLine,
+			}
+		}
+		if existing, exists := names[f.Identifier.Name]; exists {
+			return nil, &ParseError{
+				Type:    ParseErrorTypeDuplicateIdentifier,
+				Message: fmt.Sprintf("Field '%s' has been declared before. Previous declaration at %s", f.Identifier.Name, existing.Identifier.DocLine),
+				DocLine: f.Identifier.DocLine,
+			}
+		}
+		ids[f.ID] = f
+		names[f.Identifier.Name] = f
+
+		fields = append(fields, f)
+	}
+	doc.nextToken() // closing symbol
+
+	return fields, nil
+}
+
+// definitionErrorTypes contains the error type for an invalid definition of each kind
+var definitionErrorTypes = map[DefinitionKind]ParseErrorType{
+	DefinitionKindStruct:    ParseErrorTypeInvalidStructDefinition,
+	DefinitionKindUnion:     ParseErrorTypeInvalidUnionDefinition,
+	DefinitionKindException: ParseErrorTypeInvalidExceptionDefinition,
+	DefinitionKindService:   ParseErrorTypeInvalidServiceDefinition,
+}
+
+// expectDefinitionBody consumes the opening brace of a definition
+// A missing brace is reported with the error type for the kind of definition, unless the end of the document was reached.
+func (doc *Document) expectDefinitionBody(kind DefinitionKind) *ParseError {
+	_, perr := doc.expectSymbol("{")
+	if errType, exists := definitionErrorTypes[kind]; exists && perr != nil && perr.Type == ParseErrorTypeUnexpectedToken {
+		perr.Type = errType
+		perr.Message = fmt.Sprintf("Invalid %s definition. %s", kind, perr.Message)
+	}
+	return perr
+}
+
+// parseFieldsDefinition parses a definition with a block of fields (struct, union, exception), the keyword has been consumed.
+func (doc *Document) parseFieldsDefinition(kind DefinitionKind) (*Identifier, []*Field, *ParseError) {
+	identifier, perr := doc.expectDeclaration()
+	if perr != nil {
+		return nil, nil, perr
+	}
+	if perr = doc.expectDefinitionBody(kind); perr != nil {
+		return nil, nil, perr
+	}
+	fields, perr := doc.parseFieldList("}")
+	if perr != nil {
+		return nil, nil, perr
+	}
+	return identifier, fields, nil
+}
+
+// parseFunction parses a single function within a service
+// Function = ["oneway"] ( FieldType | "void" ) identifier "(" { Field } ")" [Throws] [ListSeparator] .
+// Throws   = "throws" "(" { Field } ")" .
+func (doc *Document) parseFunction() (*Function, *ParseError) {
+	f := &Function{
+		Doc: doc.peekToken().doc,
+	}
+
+	// oneway
+	if doc.peekToken().is("oneway") {
+		doc.nextToken()
+		f.Oneway = true
+	}
+
+	// return type and name
+	var perr *ParseError
+	if doc.peekToken().is("void") {
+		doc.nextToken()
+	} else {
+		f.ReturnType, perr = doc.parseFieldType()
+		if perr != nil {
+			return nil, perr
+		}
+	}
+	f.Identifier, perr = doc.expectName()
+	if perr != nil {
+		return nil, perr
+	}
+
+	// arguments
+	if _, perr = doc.expectSymbol("("); perr != nil {
+		return nil, perr
+	}
+	f.Arguments, perr = doc.parseFieldList(")")
+	if perr != nil {
+		return nil, perr
+	}
+
+	// throws
+	if doc.peekToken().is("throws") {
+		doc.nextToken()
+		if _, perr = doc.expectSymbol("("); perr != nil {
+			return nil, perr
+		}
+		f.Throws, perr = doc.parseFieldList(")")
+		if perr != nil {
+			return nil, perr
+		}
+	}
+
+	// oneway functions cannot return or throw
+	if f.Oneway && (f.ReturnType != nil || len(f.Throws) > 0) {
+		return nil, &ParseError{
+			Type:    ParseErrorTypeInvalidFunctionDefinition,
+			Message: "Invalid function definition. Oneway function must be void and cannot throw.",
+			DocLine: f.Identifier.DocLine,
+		}
+	}
+
+	doc.skipListSeparator()
+	return f, nil
+}
+
+// parseService parses a service definition, the service keyword has been consumed.
+// Service = "service" identifier ["extends" identifier] "{" { Function } "}" .
+func (doc *Document) parseService(docComment string) *ParseError {
+	identifier, perr := doc.expectDeclaration()
+	if perr != nil {
+		return perr
+	}
+
+	s := &Service{
+		Identifier: identifier,
+		Doc:        docComment,
+	}
+
+	// extended service
+	if doc.peekToken().is("extends") {
+		doc.nextToken()
+		tok, perr := doc.expectIdentifier()
+		if perr != nil {
+			return perr
+		}
+		s.Extends = IdentifierName(tok.text)
+	}
+
+	if perr = doc.expectDefinitionBody(DefinitionKindService); perr != nil {
+		return perr
+	}
 
-		line := doc.nextMeaningfulLine()
-		if len(line) == 0 {
-			break // no new lines
+	// functions
+	names := make(map[IdentifierName]*Function)
+	for !doc.peekToken().is("}") {
+		if doc.peekToken().typ == tokenEOF {
+			return doc.unexpectedToken(doc.peekToken(), "'}'")
 		}
+		f, perr := doc.parseFunction()
+		if perr != nil {
+			return perr
+		}
+		if existing, exists := names[f.Identifier.Name]; exists {
+			return &ParseError{
+				Type:    ParseErrorTypeDuplicateIdentifier,
+				Message: fmt.Sprintf("Function '%s' has been declared before in this service. Previous declaration at %s", f.Identifier.Name, existing.Identifier.DocLine),
+				DocLine: f.Identifier.DocLine,
+			}
+		}
+		names[f.Identifier.Name] = f
+		s.Functions = append(s.Functions, f)
+	}
+	doc.nextToken() // closing brace
+
+	// save service
+	doc.Services[s.Identifier.Name] = s
+	return nil
+}
 
-		// get fields from line
-		fields := strings.Fields(line)
+// skipLine consumes all tokens on the same line as the given token
+func (doc *Document) skipLine(tok *token) {
+	for next := doc.peekToken(); next.typ != tokenEOF && next.line == tok.line; next = doc.peekToken() {
+		doc.nextToken()
+	}
+}
+
+// parseDocumentHeaders parses document headers
+// An invalid header is reported and skipped, parsing continues with the next line.
+func (doc *Document) parseDocumentHeaders() (perrs ParseErrors) {
+	// loop through headers
+	for {
+		tok := doc.peekToken()
 
 		// switch on keyword
-		switch fields[0] {
-		case "include":
-			// not supporting cross-document references (yet?).
-			fmt.Printf("Ignoring include statement at %s:%d\n", doc.Name, doc.lastParsedLineNumber+1)
+		switch {
+		case tok.is("include"): // Include = "include" Literal .
+			doc.nextToken()
+			filename := doc.nextToken()
+			if filename.typ != tokenLiteral {
+				perrs = append(perrs, doc.unexpectedToken(filename, "filename"))
+				doc.skipLine(tok)
+				continue
+			}
+
+			// included documents are loaded by the TIDM after the headers have been parsed
+			doc.includeStatements = append(doc.includeStatements, &includeStatement{
+				filename: filename.text[1 : len(filename.text)-1],
+				docLine:  doc.tokenDocLine(tok),
+			})
 			continue
 
-		case "namespace":
-			// invalid namespace header. notify user, then continue.
-			if len(fields) != 3 {
-				fmt.Println("Invalid namespace header. Expecting 'namespace <target> <name>'.")
+		case tok.is("cpp_include"): // CppInclude = "cpp_include" Literal .
+			doc.nextToken()
+			// cpp_include is specific to the cpp generator of apache thrift, not supported by threft.
+			doc.skipLine(tok)
+			doc.diagnostics = append(doc.diagnostics, &Diagnostic{
+				Severity: SeverityInfo,
+				Code:     DiagnosticCodeIgnoredCppInclude,
+				Message:  "Ignoring cpp_include statement, cpp_include is not supported by threft.",
+				DocLine:  doc.rangeDocLine(tok),
+			})
+			continue
+
+		case tok.is("namespace"): // Namespace = "namespace" ( NamespaceScope identifier ) .
+			doc.nextToken()
+			start := doc.tokenIndex
+			scope := doc.nextToken()
+			name := doc.nextToken()
+
+			// invalid namespace header. warn user, then continue.
+			if !(scope.typ == tokenIdentifier || scope.is("*")) || name.typ != tokenIdentifier || isKeyword(name) ||
+				(doc.peekToken().line == tok.line && doc.peekToken().typ != tokenEOF && !isKeyword(doc.peekToken())) {
+				doc.tokenIndex = start
+				doc.skipLine(tok)
+				doc.diagnostics = append(doc.diagnostics, &Diagnostic{
+					Severity: SeverityWarning,
+					Code:     DiagnosticCodeInvalidNamespaceHeader,
+					Message:  "Ignoring invalid namespace header. Expecting 'namespace <target> <name>'.",
+					DocLine:  doc.rangeDocLine(tok),
+				})
 				continue
 			}
 
 			// add target/namespace to document
-			targetName := TargetName(fields[1])
-			namespaceName := NamespaceName(fields[2])
+			targetName := TargetName(scope.text)
+			namespaceName := NamespaceName(name.text)
 			doc.NamespaceForTarget[targetName] = namespaceName
 
-			// done, next line!
+			// done, next header!
 			continue
 
 		default:
 			// it seems that we arived at the end of the headers and now at the first definition
-			// decrement lastParsedLineNumber, this line should be parsed by parseDocumentDefinitions()
-			doc.lastParsedLineNumber--
-			// done parsing headers successfully
-			return nil
+			// done parsing headers
+			return perrs
 		}
 	}
+}
 
-	// end of document
-	return nil
+// skipToNextDefinition consumes tokens until the next token starts a definition
+// It is used to recover from an error within the definition that started at given token index,
+// so parsing can continue with the next definition.
+func (doc *Document) skipToNextDefinition(start int) {
+	// the token that caused the error can be the keyword for the next definition
+	if doc.tokenIndex > start+1 && isKeyword(doc.tokens[doc.tokenIndex-1]) {
+		doc.tokenIndex--
+	}
+	for next := doc.peekToken(); next.typ != tokenEOF && !isKeyword(next); next = doc.peekToken() {
+		doc.nextToken()
+	}
 }
 
 // parseDocumentDefinitions parses document definitions
-func (doc *Document) parseDocumentDefinitions() *ParseError {
+// When a definition is invalid the error is added to the returned list and parsing continues with the next definition.
+// Definition = Const | Typedef | Enum | Struct | Union | Exception | Service .
+func (doc *Document) parseDocumentDefinitions() (perrs ParseErrors) {
 	var countDefinitions int
 
-	// loop through lines
+	// loop through definitions
 	for {
-		line := doc.nextMeaningfulLine()
-		if len(line) == 0 {
+		start := doc.tokenIndex
+		tok := doc.nextToken()
+		if tok.typ == tokenEOF {
 			// done
 			break
 		}
 		countDefinitions++
 
-		currentDocLine := &DocLine{
-			DocumentName: doc.Name,
-			Line:         doc.lastParsedLineNumber,
-		}
-		words := strings.Fields(line)
+		var perr *ParseError
+		switch {
+		case tok.is("typedef"):
+			perr = doc.parseTypedef(tok.doc)
 
-		switch words[0] {
-		case "typedef": // Typedef = "typedef" DefinitionType identifier .
-			if len(words) != 3 {
-				return &ParseError{
-					Type:    ParseErrorTypeInvalidTypedefDefinition,
-					Message: "Invalid typedef definition.",
-					DocLine: currentDocLine,
-				}
-			}
+		case tok.is("const"):
+			perr = doc.parseConst(tok.doc)
 
-			// check identifier
-			if !regexpMatchIdentifier.MatchString(words[2]) {
-				return &ParseError{
-					Type:    ParseErrorTypeInvalidIdentifier,
-					Message: fmt.Sprintf("Invalid identifier '%s'.", words[2]),
-					DocLine: currentDocLine,
-				}
-			}
+		case tok.is("enum"):
+			perr = doc.parseEnum(tok.doc)
 
-			// create Typedef
-			t := &Typedef{
-				Identifier: &Identifier{
-					Name:    IdentifierName(words[2]),
-					DocLine: currentDocLine,
-				},
-				Type: DefinitionType(words[1]),
+		case tok.is("struct"): // Struct = "struct" identifier "{" { Field } "}" .
+			var identifier *Identifier
+			var fields []*Field
+			identifier, fields, perr = doc.parseFieldsDefinition(DefinitionKindStruct)
+			if perr != nil {
+				break
 			}
-			// save identifier and typedef
-			doc.identifiers[t.Identifier.Name] = t.Identifier
-			doc.Typedefs[t.Identifier.Name] = t
-
-		case "const": // Const = "const" FieldType ( identifier | QualifiedIdentifier ) "=" const_value .
-			if len(words) != 5 {
-				return &ParseError{
-					Type:    ParseErrorTypeInvalidConstDefinition,
-					Message: "Invalid const definition.",
-					DocLine: currentDocLine,
-				}
+			s := &Struct{
+				Identifier: identifier,
+				Doc:        tok.doc,
+				Fields:     fields,
 			}
-
-			// check for identifier to be valid
-			if !regexpMatchIdentifier.MatchString(words[2]) {
-				return &ParseError{
-					Type:    ParseErrorTypeInvalidIdentifier,
-					Message: fmt.Sprintf("Invalid identifier '%s'.", words[2]),
-					DocLine: currentDocLine,
-				}
+			doc.Structs[s.Identifier.Name] = s
+
+		case tok.is("union"): // Union = "union" identifier "{" { Field } "}" .
+			var identifier *Identifier
+			var fields []*Field
+			identifier, fields, perr = doc.parseFieldsDefinition(DefinitionKindUnion)
+			if perr != nil {
+				break
 			}
-
-			// check if identifier is unique
-			if i, exists := doc.identifiers[IdentifierName(words[2])]; exists {
-				return &ParseError{
-					Type:    ParseErrorTypeDuplicateIdentifier,
-					Message: fmt.Sprintf("The given identifier has been declared before in this document. Previous declaration at %s", i.DocLine),
-					DocLine: currentDocLine,
+			// union fields cannot be required, as only one field is set at any time
+			for _, f := range fields {
+				if f.Requiredness == FieldRequirednessRequired {
+					perrs = append(perrs, &ParseError{
+						Type:    ParseErrorTypeInvalidUnionDefinition,
+						Message: fmt.Sprintf("Union field '%s' cannot be required.", f.Identifier.Name),
+						DocLine: f.Identifier.DocLine,
+					})
 				}
 			}
-			// check that third word is an equal sign
-			if words[3] != "=" {
-				return &ParseError{
-					Type:    ParseErrorTypeInvalidConstDefinition,
-					Message: "Invalid const definition. Expecting '='.",
-					DocLine: currentDocLine,
-				}
+			u := &Union{
+				Identifier: identifier,
+				Doc:        tok.doc,
+				Fields:     fields,
 			}
-			// create constant instance
-			c := &Const{
-				Type: FieldType(words[1]),
-				Identifier: &Identifier{
-					Name:    IdentifierName(words[2]),
-					DocLine: currentDocLine,
-				},
-				Value: words[4],
+			doc.Unions[u.Identifier.Name] = u
+
+		case tok.is("exception"): // Exception = "exception" identifier "{" { Field } "}" .
+			var identifier *Identifier
+			var fields []*Field
+			identifier, fields, perr = doc.parseFieldsDefinition(DefinitionKindException)
+			if perr != nil {
+				break
 			}
-			// save identifier and constant
-			doc.identifiers[c.Identifier.Name] = c.Identifier
-			doc.Consts[c.Identifier.Name] = c
-
-		case "enum": // Enum = "enum" identifier "{" newline { identifier ["=" const_value_int] newline } "}" .
-			return &ParseError{
-				Type:    ParseErrorNotSupported,
-				Message: "Error: enum is not supported right now.",
-				DocLine: currentDocLine,
-			}
-
-		case "struct": // Struct = "struct" identifier "{" newline { Field newline } "}" .
-			return &ParseError{
-				Type:    ParseErrorNotSupported,
-				Message: "Error: struct is not supported right now.",
-				DocLine: currentDocLine,
+			e := &Exception{
+				Identifier: identifier,
+				Doc:        tok.doc,
+				Fields:     fields,
 			}
+			doc.Exceptions[e.Identifier.Name] = e
 
-		case "exception": // TODO
-			return &ParseError{
-				Type:    ParseErrorNotSupported,
-				Message: "Error: exception is not supported right now.",
-				DocLine: currentDocLine,
-			}
+		case tok.is("service"):
+			perr = doc.parseService(tok.doc)
 
-		case "service": // Service      = "service" identifier "{" newline { Function newline } "}" .
-			return &ParseError{
-				Type:    ParseErrorNotSupported,
-				Message: "Error: service is not supported right now.",
-				DocLine: currentDocLine,
+		case isKeyword(tok):
+			perr = &ParseError{
+				Type:    ParseErrorTypeUnexpectedKeyword,
+				Message: fmt.Sprintf("Error: keyword '%s' is not valid after the first definition.", tok.text),
+				DocLine: doc.tokenDocLine(tok),
 			}
 
 		default:
-			return &ParseError{
+			perr = &ParseError{
 				Type:    ParseErrorTypeUnexpectedKeyword,
-				Message: fmt.Sprintf("Error: keyword '%s' is not valid.", words[0]),
-				DocLine: currentDocLine,
+				Message: fmt.Sprintf("Error: keyword '%s' is not valid.", tok.text),
+				DocLine: doc.tokenDocLine(tok),
 			}
 		}
-		// fmt.Printf("definition: %s\n", line)
+		if perr != nil {
+			perrs = append(perrs, perr)
+			doc.skipToNextDefinition(start)
+			continue
+		}
+
+		doc.skipListSeparator()
 	}
 
 	// it is required that the document contained definitions, otherwise return an error
 	if countDefinitions == 0 {
-		return &ParseError{
+		perrs = append(perrs, &ParseError{
 			Type:    ParseErrorTypeNoDefinitionsFound,
 			Message: fmt.Sprintf("No definitions found for document '%s'. This is an error.", doc.Name),
-		}
+		})
 	}
 
 	// all done
-	return nil
+	return perrs
 }
@@ -0,0 +1,295 @@
+package tidm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// describeDocument returns a summary of the definitions in the document, independent of the source layout
+func describeDocument(doc *Document) string {
+	var lines []string
+	describeFields := func(fields []*Field) string {
+		var fs []string
+		for _, f := range fields {
+			fs = append(fs, fmt.Sprintf("%d:%s %s %s", f.ID, f.Requiredness, f.Type, f.Identifier.Name))
+		}
+		return strings.Join(fs, ", ")
+	}
+
+	for name, c := range doc.Consts {
+		lines = append(lines, fmt.Sprintf("const %s %s = %s %d %s", c.Type, name, c.Value.Kind, c.Value.Int, c.Value.String))
+	}
+	for name, td := range doc.Typedefs {
+		lines = append(lines, fmt.Sprintf("typedef %s %s", td.Type, name))
+	}
+	for name, e := range doc.Enums {
+		var values []string
+		for _, ev := range e.Values {
+			values = append(values, fmt.Sprintf("%s=%d", ev.Identifier.Name, ev.Value))
+		}
+		lines = append(lines, fmt.Sprintf("enum %s {%s}", name, strings.Join(values, ", ")))
+	}
+	for name, s := range doc.Structs {
+		lines = append(lines, fmt.Sprintf("struct %s {%s}", name, describeFields(s.Fields)))
+	}
+	for name, u := range doc.Unions {
+		lines = append(lines, fmt.Sprintf("union %s {%s}", name, describeFields(u.Fields)))
+	}
+	for name, e := range doc.Exceptions {
+		lines = append(lines, fmt.Sprintf("exception %s {%s}", name, describeFields(e.Fields)))
+	}
+	for name, s := range doc.Services {
+		var functions []string
+		for _, f := range s.Functions {
+			returnType := "void"
+			if f.ReturnType != nil {
+				returnType = f.ReturnType.String()
+			}
+			functions = append(functions, fmt.Sprintf("%s %s(%s) throws (%s)", returnType, f.Identifier.Name, describeFields(f.Arguments), describeFields(f.Throws)))
+		}
+		lines = append(lines, fmt.Sprintf("service %s extends %q {%s}", name, s.Extends, strings.Join(functions, "; ")))
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+func TestParseLayoutIndependent(t *testing.T) {
+	// each source contains the same definitions, written with a different layout
+	sources := map[string]string{
+		"one definition per line": `
+const i32 x = 1
+typedef i64 Id
+enum Color { RED, GREEN = 5, BLUE }
+struct Point { 1: i32 x, 2: optional i32 y }
+exception NotFound { 1: string why }
+service Points { Point get(1: Id id) throws (1: NotFound nf) }
+`,
+		"no whitespace": `const i32 x=1;typedef i64 Id;enum Color{RED,GREEN=5,BLUE}struct Point{1:i32 x,2:optional i32 y}exception NotFound{1:string why}service Points{Point get(1:Id id)throws(1:NotFound nf)}`,
+		"several definitions on one line": `
+const i32 x = 1; typedef i64 Id; enum Color { RED, GREEN = 5, BLUE }
+struct Point { 1: i32 x, 2: optional i32 y } exception NotFound { 1: string why }
+service Points { Point get(1: Id id) throws (1: NotFound nf) }
+`,
+		"definitions split across lines": `
+const
+  i32
+  x
+  =
+  1
+typedef i64
+  Id
+enum Color {
+  RED,
+  GREEN
+    = 5,
+  BLUE
+}
+struct Point
+{
+  1:
+    i32 x,
+  2: optional
+    i32 y
+}
+exception NotFound {
+  1: string why;
+}
+service Points {
+  Point get(
+    1: Id id
+  ) throws (
+    1: NotFound nf
+  )
+}
+`,
+		"comments everywhere": `
+const /* type */ i32 x = 1 // x
+typedef i64 Id # id
+enum Color { RED, /** green */ GREEN = 5, BLUE }
+struct Point { 1: i32 x, /* y */ 2: optional i32 y }
+exception NotFound { 1: string why }
+service Points { Point get(1: Id id) /* errors */ throws (1: NotFound nf) }
+`,
+	}
+
+	expected := strings.Join([]string{
+		"const i32 x = int 1 ",
+		"enum Color {RED=0, GREEN=5, BLUE=6}",
+		"exception NotFound {1:default string why}",
+		`service Points extends "" {Point get(1:default Id id) throws (1:default NotFound nf)}`,
+		"struct Point {1:default i32 x, 2:optional i32 y}",
+		"typedef i64 Id",
+	}, "\n")
+
+	for name, source := range sources {
+		tidm, diags := parseTestDocument(source)
+		if diags.HasErrors() {
+			t.Errorf("%s: unexpected errors: %v", name, diags)
+			continue
+		}
+		if got := describeDocument(tidm.Documents["test.thrift"]); got != expected {
+			t.Errorf("%s: expected\n%s\ngot\n%s", name, expected, got)
+		}
+	}
+}
+
+func TestParseConstWithoutWhitespace(t *testing.T) {
+	tidm, diags := parseTestDocument("const i32 x=1")
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %v", diags)
+	}
+	c := tidm.Documents["test.thrift"].Consts["x"]
+	if c == nil {
+		t.Fatalf("const x was not parsed")
+	}
+	if c.Type.Name != "i32" || c.Value.Kind != ConstValueKindInt || c.Value.Int != 1 {
+		t.Errorf("expected const i32 x = 1, got %s x = %+v", c.Type, c.Value)
+	}
+}
+
+func TestParseConstValues(t *testing.T) {
+	tests := []struct {
+		source string
+		kind   ConstValueKind
+		value  interface{}
+	}{
+		{"const i64 x = 0x1F", ConstValueKindInt, int64(31)},
+		{"const i64 x = -0x10", ConstValueKindInt, int64(-16)},
+		{"const i32 x = 010", ConstValueKindInt, int64(10)},
+		{"const i32 x = 09", ConstValueKindInt, int64(9)},
+		{"const i32 x = -010", ConstValueKindInt, int64(-10)},
+		{"const i64 x = 0XfF", ConstValueKindInt, int64(255)},
+		{"const double x = 2.5e2", ConstValueKindDouble, 250.0},
+		{"const double x = -.5", ConstValueKindDouble, -0.5},
+		{`const string x = "a\"b\n"`, ConstValueKindString, "a\"b\n"},
+		{`const string x = 'it\'s'`, ConstValueKindString, "it's"},
+		{"const bool x = true", ConstValueKindBool, true},
+	}
+
+	for _, test := range tests {
+		tidm, diags := parseTestDocument(test.source)
+		if diags.HasErrors() {
+			t.Errorf("%s: unexpected errors: %v", test.source, diags)
+			continue
+		}
+		cv := tidm.Documents["test.thrift"].Consts["x"].Value
+		var value interface{}
+		switch cv.Kind {
+		case ConstValueKindInt:
+			value = cv.Int
+		case ConstValueKindDouble:
+			value = cv.Double
+		case ConstValueKindString:
+			value = cv.String
+		case ConstValueKindBool:
+			value = cv.Bool
+		}
+		if cv.Kind != test.kind || value != test.value {
+			t.Errorf("%s: expected %s %v, got %s %v", test.source, test.kind, test.value, cv.Kind, value)
+		}
+	}
+}
+
+func TestParseEnumValues(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected string
+	}{
+		{"enum E { A, B, C }", "enum E {A=0, B=1, C=2}"},
+		{"enum E { A = 010, B }", "enum E {A=10, B=11}"},
+		{"enum E { A = 09, B = 0x10, C = -1 }", "enum E {A=9, B=16, C=-1}"},
+	}
+
+	for _, test := range tests {
+		tidm, diags := parseTestDocument(test.source)
+		if diags.HasErrors() {
+			t.Errorf("%s: unexpected errors: %v", test.source, diags)
+			continue
+		}
+		if got := describeDocument(tidm.Documents["test.thrift"]); got != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.source, test.expected, got)
+		}
+	}
+}
+
+func TestParseFieldIDs(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected string
+	}{
+		{"struct S { 1: i32 a, 2: i32 b }", "struct S {1:default i32 a, 2:default i32 b}"},
+		{"struct S { 010: i32 a, 09: i32 b }", "struct S {10:default i32 a, 9:default i32 b}"},
+		{"struct S { 0x10: i32 a }", "struct S {16:default i32 a}"},
+	}
+
+	for _, test := range tests {
+		tidm, diags := parseTestDocument(test.source)
+		if diags.HasErrors() {
+			t.Errorf("%s: unexpected errors: %v", test.source, diags)
+			continue
+		}
+		if got := describeDocument(tidm.Documents["test.thrift"]); got != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.source, test.expected, got)
+		}
+	}
+}
+
+func TestParseInvalidNames(t *testing.T) {
+	// qualified names are lexed as a single identifier, but are not valid for declarations
+	sources := []string{
+		"struct A.B {}",
+		"enum E { A.B }",
+		"struct S { 1: i32 a.b }",
+		"service X { void f.g() }",
+		"service X { void f(1: i32 a.b) }",
+	}
+
+	for _, source := range sources {
+		_, diags := parseTestDocument(source)
+		if len(diags) != 1 || diags[0].Code != ParseErrorTypeInvalidIdentifier.Code() {
+			t.Errorf("%s: expected an invalid identifier error, got %v", source, diags)
+		}
+	}
+}
+
+func TestParseErrorRecovery(t *testing.T) {
+	// each invalid definition is reported, parsing continues with the next definition
+	source := "struct A { 1: i32 }\nconst i32 = 1\nstruct B { 1: i32 b }\nenum { X }\n"
+	tidm, diags := parseTestDocument(source)
+	if got := diags.Count(SeverityError); got != 3 {
+		t.Fatalf("expected 3 errors, got %v", diags)
+	}
+	for i, line := range []int{0, 1, 3} {
+		if diags[i].DocLine == nil || diags[i].DocLine.Line != line {
+			t.Errorf("expected error %d on line %d, got %v", i, line+1, diags[i])
+		}
+	}
+	if tidm.Documents["test.thrift"].Structs["B"] == nil {
+		t.Errorf("struct B after an invalid definition was not parsed")
+	}
+}
+
+func TestParseDefinitionErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		code   DiagnosticCode
+	}{
+		{"struct S ( 1: i32 a )", ParseErrorTypeInvalidStructDefinition.Code()},
+		{"union U 1: i32 a }", ParseErrorTypeInvalidUnionDefinition.Code()},
+		{"exception E = { 1: string why }", ParseErrorTypeInvalidExceptionDefinition.Code()},
+		{"service S extends Base void ping() }\nservice Base {}", ParseErrorTypeInvalidServiceDefinition.Code()},
+		{"struct Base {}\nservice S extends Base {}", ParseErrorTypeInvalidServiceDefinition.Code()},
+		{"service S extends Base {}", ParseErrorTypeUnknownIdentifier.Code()},
+		{"struct S", ParseErrorTypeUnexpectedEndOfDocument.Code()},
+		{"struct S { 1: i32 a", ParseErrorTypeUnexpectedEndOfDocument.Code()},
+	}
+
+	for _, test := range tests {
+		_, diags := parseTestDocument(test.source)
+		if len(diags) != 1 || diags[0].Code != test.code {
+			t.Errorf("%s: expected an error with code %s, got %v", test.source, test.code, diags)
+		}
+	}
+}
@@ -18,6 +18,9 @@ type EnumReference Reference
 // StructReference references a Struct within the TIDM
 type StructReference Reference
 
+// UnionReference references a Union within the TIDM
+type UnionReference Reference
+
 // ExceptionReference references an Exception within the TIDM
 type ExceptionReference Reference
 
@@ -0,0 +1,277 @@
+package tidm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// lookupIdentifier finds the definition for a name as used in given document.
+// The name can be qualified with the prefix of an included document (`shared.SharedStruct`).
+// The returned Reference is nil when the name cannot be found.
+func (t *TIDM) lookupIdentifier(doc *Document, name string) *Reference {
+	if pos := strings.Index(name, "."); pos > -1 {
+		includedName, exists := doc.Includes[name[:pos]]
+		if !exists {
+			return nil
+		}
+		doc = t.Documents[includedName]
+		name = name[pos+1:]
+	}
+
+	if _, exists := doc.identifiers[IdentifierName(name)]; !exists {
+		return nil
+	}
+	return &Reference{
+		DocumentName:   doc.Name,
+		IdentifierName: IdentifierName(name),
+	}
+}
+
+// resolveFieldType links a named type (and named types within a container type) to its definition.
+func (t *TIDM) resolveFieldType(doc *Document, ft *FieldType) *ParseError {
+	switch ft.Kind {
+	case FieldTypeKindList, FieldTypeKindSet:
+		return t.resolveFieldType(doc, ft.ElemType)
+
+	case FieldTypeKindMap:
+		perr := t.resolveFieldType(doc, ft.KeyType)
+		if perr != nil {
+			return perr
+		}
+		return t.resolveFieldType(doc, ft.ValueType)
+
+	case FieldTypeKindNamed:
+		ref := t.lookupIdentifier(doc, ft.Name)
+		if ref == nil {
+			return &ParseError{
+				Type:    ParseErrorTypeUnknownIdentifier,
+				Message: fmt.Sprintf("Unknown type '%s'.", ft.Name),
+				DocLine: ft.DocLine,
+			}
+		}
+		kind := t.Documents[ref.DocumentName].definitionKind(ref.IdentifierName)
+		switch kind {
+		case DefinitionKindTypedef, DefinitionKindEnum, DefinitionKindStruct, DefinitionKindUnion, DefinitionKindException:
+			ft.Reference = ref
+			ft.ReferenceKind = kind
+		default:
+			return &ParseError{
+				Type:    ParseErrorTypeUnknownIdentifier,
+				Message: fmt.Sprintf("Cannot use %s '%s' as type.", kind, ft.Name),
+				DocLine: ft.DocLine,
+			}
+		}
+	}
+	return nil
+}
+
+// resolveFields resolves the types for given fields
+func (t *TIDM) resolveFields(doc *Document, fields []*Field) (perrs ParseErrors) {
+	for _, f := range fields {
+		perr := t.resolveFieldType(doc, f.Type)
+		if perr != nil {
+			perrs = append(perrs, perr)
+		}
+	}
+	return perrs
+}
+
+// resolveReferences links names used in definitions to the definitions they refer to
+// All names are resolved, each name that cannot be resolved is added to the returned errors.
+func (t *TIDM) resolveReferences() (perrs ParseErrors) {
+	for _, doc := range t.sortedDocuments() {
+		// resolve types
+		for _, td := range doc.Typedefs {
+			if perr := t.resolveFieldType(doc, td.Type); perr != nil {
+				perrs = append(perrs, perr)
+			}
+		}
+		for _, c := range doc.Consts {
+			if perr := t.resolveFieldType(doc, c.Type); perr != nil {
+				perrs = append(perrs, perr)
+			}
+		}
+		for _, s := range doc.Structs {
+			perrs = append(perrs, t.resolveFields(doc, s.Fields)...)
+		}
+		for _, u := range doc.Unions {
+			perrs = append(perrs, t.resolveFields(doc, u.Fields)...)
+		}
+		for _, e := range doc.Exceptions {
+			perrs = append(perrs, t.resolveFields(doc, e.Fields)...)
+		}
+		for _, s := range doc.Services {
+			for _, f := range s.Functions {
+				if f.ReturnType != nil {
+					if perr := t.resolveFieldType(doc, f.ReturnType); perr != nil {
+						perrs = append(perrs, perr)
+					}
+				}
+				perrs = append(perrs, t.resolveFields(doc, f.Arguments)...)
+				perrs = append(perrs, t.resolveFields(doc, f.Throws)...)
+				// functions can only throw exceptions
+				for _, throw := range f.Throws {
+					if throw.Type.Kind == FieldTypeKindNamed && throw.Type.Reference == nil {
+						continue // unknown type, reported above
+					}
+					if throw.Type.ReferenceKind != DefinitionKindException {
+						perrs = append(perrs, &ParseError{
+							Type:    ParseErrorTypeInvalidFunctionDefinition,
+							Message: fmt.Sprintf("Function '%s' can only throw exceptions, '%s' is not an exception.", f.Identifier.Name, throw.Type),
+							DocLine: throw.Type.DocLine,
+						})
+					}
+				}
+			}
+		}
+
+		// resolve extended services
+		for _, s := range doc.Services {
+			if len(s.Extends) == 0 {
+				continue
+			}
+			ref := t.lookupIdentifier(doc, string(s.Extends))
+			if ref == nil {
+				perrs = append(perrs, &ParseError{
+					Type:    ParseErrorTypeUnknownIdentifier,
+					Message: fmt.Sprintf("Unknown service '%s'.", s.Extends),
+					DocLine: s.Identifier.DocLine,
+				})
+				continue
+			}
+			if kind := t.Documents[ref.DocumentName].definitionKind(ref.IdentifierName); kind != DefinitionKindService {
+				perrs = append(perrs, &ParseError{
+					Type:    ParseErrorTypeInvalidServiceDefinition,
+					Message: fmt.Sprintf("Service '%s' cannot extend %s '%s', only services can be extended.", s.Identifier.Name, kind, s.Extends),
+					DocLine: s.Identifier.DocLine,
+				})
+				continue
+			}
+			s.ExtendsReference = (*ServiceReference)(ref)
+		}
+	}
+	return perrs
+}
+
+// UnderlyingType returns the type for given type, following typedefs until a type is found that is not a typedef.
+// Element, key and value types of a container type are not followed, call UnderlyingType on those when required.
+// The TIDM must be parsed, as named types are resolved by Parse().
+func (t *TIDM) UnderlyingType(ft *FieldType) (*FieldType, error) {
+	seen := make(map[Reference]bool)
+	for ft.ReferenceKind == DefinitionKindTypedef {
+		if seen[*ft.Reference] {
+			return nil, ErrTypedefCycle
+		}
+		seen[*ft.Reference] = true
+
+		td, err := t.Typedef(TypedefReference(*ft.Reference))
+		if err != nil {
+			return nil, err
+		}
+		ft = td.Type
+	}
+	return ft, nil
+}
+
+// checkTypedefCycles returns a ParseError for each typedef cycle, a typedef that refers to itself directly or through other typedefs.
+// Each cycle is reported once, at the first typedef of the cycle in order of declaration.
+func (t *TIDM) checkTypedefCycles() (perrs ParseErrors) {
+	inCycle := make(map[*Typedef]bool) // typedefs in a cycle that was reported
+	for _, doc := range t.sortedDocuments() {
+		typedefs := make([]*Typedef, 0, len(doc.Typedefs))
+		for _, td := range doc.Typedefs {
+			typedefs = append(typedefs, td)
+		}
+		sort.Slice(typedefs, func(i, j int) bool {
+			return docLineLess(typedefs[i].Identifier.DocLine, typedefs[j].Identifier.DocLine)
+		})
+
+		for _, td := range typedefs {
+			if inCycle[td] {
+				continue
+			}
+
+			// follow the chain of typedefs, starting at td
+			chain := []*Typedef{td}
+			seen := map[*Typedef]bool{td: true}
+			for ft := td.Type; ft.ReferenceKind == DefinitionKindTypedef; {
+				next := t.Documents[ft.Reference.DocumentName].Typedefs[ft.Reference.IdentifierName]
+				if next == td {
+					steps := make([]string, 0, len(chain))
+					related := make([]*DocLine, 0, len(chain))
+					for _, step := range chain {
+						steps = append(steps, fmt.Sprintf("%s typedef '%s' is '%s'", step.Identifier.DocLine, step.Identifier.Name, step.Type))
+						related = append(related, step.Identifier.DocLine)
+						inCycle[step] = true
+					}
+					perrs = append(perrs, &ParseError{
+						Type:    ParseErrorTypeTypedefCycle,
+						Message: fmt.Sprintf("Typedef cycle detected: %s.", strings.Join(steps, ", ")),
+						DocLine: td.Identifier.DocLine,
+						Related: related,
+					})
+					break
+				}
+				if seen[next] {
+					break // cycle that does not include td, reported when starting at a typedef within that cycle
+				}
+				seen[next] = true
+				chain = append(chain, next)
+				ft = next.Type
+			}
+		}
+	}
+	return perrs
+}
+
+// checkServiceExtendsCycles returns a ParseError for each cycle of services that extend each other, or a service that extends itself.
+// Each cycle is reported once, at the first service of the cycle in order of declaration.
+func (t *TIDM) checkServiceExtendsCycles() (perrs ParseErrors) {
+	inCycle := make(map[*Service]bool) // services in a cycle that was reported
+	for _, doc := range t.sortedDocuments() {
+		services := make([]*Service, 0, len(doc.Services))
+		for _, s := range doc.Services {
+			services = append(services, s)
+		}
+		sort.Slice(services, func(i, j int) bool {
+			return docLineLess(services[i].Identifier.DocLine, services[j].Identifier.DocLine)
+		})
+
+		for _, s := range services {
+			if inCycle[s] {
+				continue
+			}
+
+			// follow the chain of extended services, starting at s
+			chain := []*Service{s}
+			seen := map[*Service]bool{s: true}
+			for ref := s.ExtendsReference; ref != nil; {
+				next := t.Documents[ref.DocumentName].Services[ref.IdentifierName]
+				if next == s {
+					steps := make([]string, 0, len(chain))
+					related := make([]*DocLine, 0, len(chain))
+					for _, step := range chain {
+						steps = append(steps, fmt.Sprintf("%s service '%s' extends '%s'", step.Identifier.DocLine, step.Identifier.Name, step.Extends))
+						related = append(related, step.Identifier.DocLine)
+						inCycle[step] = true
+					}
+					perrs = append(perrs, &ParseError{
+						Type:    ParseErrorTypeInvalidServiceDefinition,
+						Message: fmt.Sprintf("Service extends cycle detected: %s.", strings.Join(steps, ", ")),
+						DocLine: s.Identifier.DocLine,
+						Related: related,
+					})
+					break
+				}
+				if seen[next] {
+					break // cycle that does not include s, reported when starting at a service within that cycle
+				}
+				seen[next] = true
+				chain = append(chain, next)
+				ref = next.ExtendsReference
+			}
+		}
+	}
+	return perrs
+}
@@ -0,0 +1,112 @@
+package tidm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckTypedefCycles(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		cycles []string // expected cycles, as the names of the typedef where each cycle is reported
+	}{
+		{"no cycle", "typedef i32 a\ntypedef a b\ntypedef b c", nil},
+		{"self", "typedef c c", []string{"c"}},
+		{"two typedefs", "typedef b a\ntypedef a b", []string{"a"}},
+		{"several cycles", "typedef b a\ntypedef a b\ntypedef c c\ntypedef e d\ntypedef f e\ntypedef d f", []string{"a", "c", "d"}},
+		{"chain into cycle", "typedef b x\ntypedef b a\ntypedef a b", []string{"a"}},
+	}
+
+	for _, test := range tests {
+		_, diags := parseTestDocument(test.source)
+		if len(diags) != len(test.cycles) {
+			t.Errorf("%s: expected %d errors, got %v", test.name, len(test.cycles), diags)
+			continue
+		}
+		for i, d := range diags {
+			if d.Code != ParseErrorTypeTypedefCycle.Code() || !strings.Contains(d.Message, "typedef '"+test.cycles[i]+"'") {
+				t.Errorf("%s: expected typedef cycle at '%s', got %s", test.name, test.cycles[i], d)
+			}
+		}
+	}
+}
+
+func TestCheckServiceExtendsCycles(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		cycles []string // expected cycles, as the names of the service where each cycle is reported
+	}{
+		{"no cycle", "service A {}\nservice B extends A {}\nservice C extends B {}", nil},
+		{"self", "service A extends A {}", []string{"A"}},
+		{"two services", "service A extends B {}\nservice B extends A {}", []string{"A"}},
+		{"several cycles", "service A extends B {}\nservice B extends A {}\nservice C extends C {}\nservice D extends A {}", []string{"A", "C"}},
+	}
+
+	for _, test := range tests {
+		_, diags := parseTestDocument(test.source)
+		if len(diags) != len(test.cycles) {
+			t.Errorf("%s: expected %d errors, got %v", test.name, len(test.cycles), diags)
+			continue
+		}
+		for i, d := range diags {
+			if d.Code != ParseErrorTypeInvalidServiceDefinition.Code() || !strings.Contains(d.Message, "service '"+test.cycles[i]+"'") {
+				t.Errorf("%s: expected service extends cycle at '%s', got %s", test.name, test.cycles[i], d)
+			}
+		}
+		if len(test.cycles) > 0 && len(diags[0].Related) == 0 {
+			t.Errorf("%s: expected the services in the cycle as related DocLines", test.name)
+		}
+	}
+}
+
+func TestQualifiedReferences(t *testing.T) {
+	shared := "struct MyStruct { 1: i32 a }\nenum E { A, B }\nconst i32 N = 1"
+	tests := []struct {
+		name   string
+		source string
+		err    string // part of the expected error message, empty when the source is valid
+	}{
+		{"struct field", "include \"shared.thrift\"\nstruct S { 1: shared.MyStruct m }", ""},
+		{"container of qualified types", "include \"shared.thrift\"\nstruct S { 1: map<shared.E, list<shared.MyStruct>> m }", ""},
+		{"enum value", "include \"shared.thrift\"\nconst shared.E X = shared.E.B", ""},
+		{"const", "include \"shared.thrift\"\nconst i32 X = shared.N", ""},
+		{"unknown type", "include \"shared.thrift\"\nstruct S { 1: shared.Missing m }", "Unknown type 'shared.Missing'."},
+		{"prefix not included", "struct S { 1: shared.MyStruct m }", "Unknown type 'shared.MyStruct'."},
+		{"unqualified type from include", "include \"shared.thrift\"\nstruct S { 1: MyStruct m }", "Unknown type 'MyStruct'."},
+	}
+
+	for _, test := range tests {
+		tidm := NewTIDM()
+		tidm.AddDocument(DocumentName("shared.thrift"), strings.NewReader(shared))
+		tidm.AddDocument(DocumentName("main.thrift"), strings.NewReader(test.source))
+		diags := tidm.Parse()
+		switch {
+		case test.err == "" && diags.HasErrors():
+			t.Errorf("%s: unexpected errors: %v", test.name, diags)
+		case test.err != "" && (len(diags) != 1 || !strings.Contains(diags[0].Message, test.err)):
+			t.Errorf("%s: expected 1 error containing %q, got: %v", test.name, test.err, diags)
+		}
+	}
+}
+
+func TestQualifiedReferenceTargets(t *testing.T) {
+	tidm := NewTIDM()
+	tidm.AddDocument(DocumentName("shared.thrift"), strings.NewReader("struct MyStruct { 1: i32 a }\nenum E { A, B }"))
+	tidm.AddDocument(DocumentName("main.thrift"), strings.NewReader("include \"shared.thrift\"\nstruct S { 1: shared.MyStruct m }\nconst shared.E X = shared.E.B"))
+	if diags := tidm.Parse(); diags.HasErrors() {
+		t.Fatalf("unexpected errors: %v", diags)
+	}
+	doc := tidm.Documents["main.thrift"]
+
+	ft := doc.Structs["S"].Fields[0].Type
+	if ft.Reference == nil || *ft.Reference != (Reference{"shared.thrift", "MyStruct"}) || ft.ReferenceKind != DefinitionKindStruct {
+		t.Errorf("expected field m to reference struct shared.thrift MyStruct, got %+v (%s)", ft.Reference, ft.ReferenceKind)
+	}
+
+	cv := doc.Consts["X"].Value
+	if cv.Kind != ConstValueKindEnumValue || cv.EnumReference == nil || *cv.EnumReference != (EnumReference{"shared.thrift", "E"}) || cv.EnumValue != "B" || cv.Int != 1 {
+		t.Errorf("expected X to be value B of enum shared.thrift E, got %+v", cv)
+	}
+}
@@ -5,12 +5,18 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sort"
+	"strings"
 )
 
 var (
 	ErrNotParsedYet = errors.New("Cannot get a Target from an unparsed TIDM.")
+	ErrTypedefCycle = errors.New("Typedef refers to itself through a chain of typedefs.")
 )
 
+// DefaultExtensions are the file extensions for IDL documents known to every TIDM, see AddExtension()
+var DefaultExtensions = []string{".thrift", ".threft"}
+
 // The TIDM is the top-level object for Threft Interface Definition Model.
 // It contains documents and targets.
 type TIDM struct {
@@ -22,7 +28,11 @@ type TIDM struct {
 	documentNameMaxLength int // Longest name, for pretty printing
 
 	// private stuff, must be populated
-	parsed bool // true when TIDM was parsed
+	parsed        bool                   // true when TIDM was parsed
+	includePaths  []string               // paths to search for included documents, see AddIncludePath()
+	extensions    []string               // file extensions for IDL documents, see AddExtension()
+	documentPaths map[string]*Document   // documents read from file, by absolute path. Used to load each file only once.
+	constChecks   map[*Const]*constCheck // consts that have been checked, see checkConstValues()
 }
 
 // newTIDM sets up a new and empty TIDM
@@ -30,6 +40,9 @@ func newTIDM() *TIDM {
 	return &TIDM{
 		Documents: make(map[DocumentName]*Document),
 		Targets:   make(map[TargetName]*Target),
+
+		documentPaths: make(map[string]*Document),
+		extensions:    append([]string(nil), DefaultExtensions...),
 	}
 }
 
@@ -61,6 +74,90 @@ func (t *TIDM) Const(ref ConstReference) (*Const, error) {
 	return con, nil
 }
 
+// Typedef returns a *Typedef for given TypedefReference, or an error when Typedef cannot be found.
+func (t *TIDM) Typedef(ref TypedefReference) (*Typedef, error) {
+	doc, err := t.Document(Reference(ref))
+	if err != nil {
+		return nil, err
+	}
+
+	td, exists := doc.Typedefs[ref.IdentifierName]
+	if !exists {
+		return nil, errors.New("Typedef for given TypedefReference does not exist.")
+	}
+	return td, nil
+}
+
+// Enum returns an *Enums for given EnumReference, or an error when Enum cannot be found.
+func (t *TIDM) Enum(ref EnumReference) (*Enums, error) {
+	doc, err := t.Document(Reference(ref))
+	if err != nil {
+		return nil, err
+	}
+
+	e, exists := doc.Enums[ref.IdentifierName]
+	if !exists {
+		return nil, errors.New("Enum for given EnumReference does not exist.")
+	}
+	return e, nil
+}
+
+// Struct returns a *Struct for given StructReference, or an error when Struct cannot be found.
+func (t *TIDM) Struct(ref StructReference) (*Struct, error) {
+	doc, err := t.Document(Reference(ref))
+	if err != nil {
+		return nil, err
+	}
+
+	s, exists := doc.Structs[ref.IdentifierName]
+	if !exists {
+		return nil, errors.New("Struct for given StructReference does not exist.")
+	}
+	return s, nil
+}
+
+// Union returns a *Union for given UnionReference, or an error when Union cannot be found.
+func (t *TIDM) Union(ref UnionReference) (*Union, error) {
+	doc, err := t.Document(Reference(ref))
+	if err != nil {
+		return nil, err
+	}
+
+	u, exists := doc.Unions[ref.IdentifierName]
+	if !exists {
+		return nil, errors.New("Union for given UnionReference does not exist.")
+	}
+	return u, nil
+}
+
+// Exception returns an *Exception for given ExceptionReference, or an error when Exception cannot be found.
+func (t *TIDM) Exception(ref ExceptionReference) (*Exception, error) {
+	doc, err := t.Document(Reference(ref))
+	if err != nil {
+		return nil, err
+	}
+
+	e, exists := doc.Exceptions[ref.IdentifierName]
+	if !exists {
+		return nil, errors.New("Exception for given ExceptionReference does not exist.")
+	}
+	return e, nil
+}
+
+// Service returns a *Service for given ServiceReference, or an error when Service cannot be found.
+func (t *TIDM) Service(ref ServiceReference) (*Service, error) {
+	doc, err := t.Document(Reference(ref))
+	if err != nil {
+		return nil, err
+	}
+
+	s, exists := doc.Services[ref.IdentifierName]
+	if !exists {
+		return nil, errors.New("Service for given ServiceReference does not exist.")
+	}
+	return s, nil
+}
+
 // Encode tidm-json to given writer
 func (t *TIDM) EncodeTo(w io.Writer) (err error) {
 	enc := json.NewEncoder(w)
@@ -94,29 +191,122 @@ func (t *TIDM) AddDocument(name DocumentName, reader io.Reader) error {
 	return err
 }
 
-// Parse parses and verifies the complete TIDM tree (each document, each target, each namespace)
-func (t *TIDM) Parse() *ParseError {
+// AddDocumentFile adds the document from given file to the TIDM docTree
+// The base filename is used as DocumentName. Included documents are searched relative to the file first.
+// Adding the same file more than once has no effect.
+func (t *TIDM) AddDocumentFile(filename string) error {
+	return t.AddDocumentFileWithName("", filename)
+}
+
+// AddDocumentFileWithName adds the document from given file to the TIDM docTree, with given DocumentName.
+// Use this when files from different folders can have the same base filename, e.g. with the path relative to a root folder as name.
+// The base filename is used when name is empty. Adding the same file more than once has no effect.
+func (t *TIDM) AddDocumentFileWithName(name DocumentName, filename string) error {
 	if t.parsed {
 		return &ParseError{
 			Type:    ParseErrorTypeAlreadyParsed,
-			Message: "Cannot parse an already parsed TIDM structure.",
+			Message: "Cannot add a document after the TIDM has been parsed.",
+		}
+	}
+
+	_, err := t.newDocumentFromFile(name, filename)
+	if err == ErrDocumentWithPathExists {
+		return nil
+	}
+	return err
+}
+
+// AddIncludePath adds a path to search for included documents
+// Include paths are searched in the order they were added, after the directory of the including document.
+func (t *TIDM) AddIncludePath(path string) {
+	t.includePaths = append(t.includePaths, path)
+}
+
+// AddExtension adds a file extension for IDL documents, in addition to the DefaultExtensions
+// The leading dot is optional, "idl" and ".idl" are equal.
+func (t *TIDM) AddExtension(ext string) {
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	for _, existing := range t.extensions {
+		if existing == ext {
+			return
+		}
+	}
+	t.extensions = append(t.extensions, ext)
+}
+
+// Extensions returns the file extensions for IDL documents
+func (t *TIDM) Extensions() []string {
+	return append([]string(nil), t.extensions...)
+}
+
+// IsIDLFile returns true when the filename has one of the file extensions for IDL documents
+func (t *TIDM) IsIDLFile(filename string) bool {
+	return len(t.idlExtension(filename)) > 0
+}
+
+// idlExtension returns the IDL file extension for the filename, or an empty string when the filename has no IDL file extension
+func (t *TIDM) idlExtension(filename string) string {
+	for _, ext := range t.extensions {
+		if strings.HasSuffix(filename, ext) && len(filename) > len(ext) {
+			return ext
 		}
 	}
+	return ""
+}
+
+// Parse parses and verifies the complete TIDM tree (each document, each target, each namespace)
+// All diagnostics (errors, warnings and info messages) are returned, sorted by document and line.
+// The TIDM is valid when the returned diagnostics contain no errors, see Diagnostics.HasErrors().
+func (t *TIDM) Parse() Diagnostics {
+	if t.parsed {
+		return Diagnostics{(&ParseError{
+			Type:    ParseErrorTypeAlreadyParsed,
+			Message: "Cannot parse an already parsed TIDM structure.",
+		}).Diagnostic()}
+	}
 	t.parsed = true
 
+	perrs := t.parseAndVerify()
+
+	// collect warnings and info messages from the documents, and add the errors
+	var diags Diagnostics
+	for _, doc := range t.sortedDocuments() {
+		diags = append(diags, doc.diagnostics...)
+	}
+	for _, perr := range perrs {
+		diags = append(diags, perr.Diagnostic())
+	}
+	diags.sort()
+	return diags
+}
+
+// parseAndVerify parses all documents and verifies the complete TIDM tree
+// Parsing continues after errors so that all syntax errors are reported at once.
+// Verification stops at the first step that found errors, as following steps depend on it.
+func (t *TIDM) parseAndVerify() ParseErrors {
+	// documents to parse, included documents are appended when they are loaded
+	docs := t.sortedDocuments()
+
 	// parse all documents
-	for _, doc := range t.Documents {
+	var perrs ParseErrors
+	for i := 0; i < len(docs); i++ {
+		doc := docs[i]
+
+		// tokenize source
+		perrs = append(perrs, doc.tokenize()...)
+
 		// parse headers
-		perr := doc.parseDocumentHeaders()
-		if perr != nil {
-			return perr
-		}
+		perrs = append(perrs, doc.parseDocumentHeaders()...)
+
+		// load included documents
+		includedDocs, includePerrs := t.loadIncludes(doc)
+		perrs = append(perrs, includePerrs...)
+		docs = append(docs, includedDocs...)
 
 		// parse definitions
-		perr = doc.parseDocumentDefinitions()
-		if perr != nil {
-			return perr
-		}
+		perrs = append(perrs, doc.parseDocumentDefinitions()...)
 
 		// add defined Targets to TIDM Targets map
 		for targetName, _ := range doc.NamespaceForTarget {
@@ -126,17 +316,59 @@ func (t *TIDM) Parse() *ParseError {
 			}
 		}
 	}
+	if len(perrs) > 0 {
+		return perrs
+	}
+
+	// include graph must not contain cycles
+	if perrs = t.checkIncludeCycles(); len(perrs) > 0 {
+		return perrs
+	}
+
+	// resolve references between definitions
+	if perrs = t.resolveReferences(); len(perrs) > 0 {
+		return perrs
+	}
+
+	// typedef chains must end in a type that is not a typedef
+	if perrs = t.checkTypedefCycles(); len(perrs) > 0 {
+		return perrs
+	}
+
+	// services must not extend themselves, directly or through other services
+	if perrs = t.checkServiceExtendsCycles(); len(perrs) > 0 {
+		return perrs
+	}
+
+	// const values must match their type
+	if perrs = t.checkConstValues(); len(perrs) > 0 {
+		return perrs
+	}
 
 	// loop through targets and populate them with the parsed data
 	for targetName, _ := range t.Targets {
-		perr := t.populateTarget(targetName)
-		if perr != nil {
-			return perr
+		if perr := t.populateTarget(targetName); perr != nil {
+			perrs = append(perrs, perr)
 		}
 	}
 
 	// all done
-	return nil
+	return perrs
+}
+
+// sortedDocuments returns all documents, sorted by name
+func (t *TIDM) sortedDocuments() []*Document {
+	names := make([]string, 0, len(t.Documents))
+	for name := range t.Documents {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+
+	docs := make([]*Document, 0, len(names))
+	for _, name := range names {
+		docs = append(docs, t.Documents[DocumentName(name)])
+	}
+	return docs
 }
 
 // Target() returns a Target for given TargetName
@@ -201,7 +433,41 @@ func (t *TIDM) populateTarget(targetName TargetName) *ParseError {
 			namespace.ConstReferences[c.Identifier.Name] = &ConstReference{doc.Name, c.Identifier.Name}
 		}
 
-		//++ add other definitions to target namespace
+		// add typedef definitions to target namespace
+		for _, td := range doc.Typedefs {
+			namespace.identifiers[td.Identifier.Name] = td.Identifier
+			namespace.TypedefReferences[td.Identifier.Name] = &TypedefReference{doc.Name, td.Identifier.Name}
+		}
+
+		// add enum definitions to target namespace
+		for _, e := range doc.Enums {
+			namespace.identifiers[e.Identifier.Name] = e.Identifier
+			namespace.EnumReferences[e.Identifier.Name] = &EnumReference{doc.Name, e.Identifier.Name}
+		}
+
+		// add struct definitions to target namespace
+		for _, s := range doc.Structs {
+			namespace.identifiers[s.Identifier.Name] = s.Identifier
+			namespace.StructReferences[s.Identifier.Name] = &StructReference{doc.Name, s.Identifier.Name}
+		}
+
+		// add union definitions to target namespace
+		for _, u := range doc.Unions {
+			namespace.identifiers[u.Identifier.Name] = u.Identifier
+			namespace.UnionReferences[u.Identifier.Name] = &UnionReference{doc.Name, u.Identifier.Name}
+		}
+
+		// add exception definitions to target namespace
+		for _, e := range doc.Exceptions {
+			namespace.identifiers[e.Identifier.Name] = e.Identifier
+			namespace.ExceptionReferences[e.Identifier.Name] = &ExceptionReference{doc.Name, e.Identifier.Name}
+		}
+
+		// add service definitions to target namespace
+		for _, s := range doc.Services {
+			namespace.identifiers[s.Identifier.Name] = s.Identifier
+			namespace.ServiceReferences[s.Identifier.Name] = &ServiceReference{doc.Name, s.Identifier.Name}
+		}
 	}
 	return nil
 }